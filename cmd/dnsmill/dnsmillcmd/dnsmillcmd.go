@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
 	"text/tabwriter"
 
@@ -32,19 +33,71 @@ func init() {
 	pflag.BoolVarP(&verbose, "verbose", "v", false, "enable debug logging")
 	pflag.BoolVar(&dryRun, "dry-run", false, "enable dry-run mode")
 	pflag.BoolVarP(&jsonLog, "json-log", "j", false, "log in JSON output instead of text")
-	pflag.StringVarP(&format, "format", "f", "yaml", "profile format (json or yaml, empty to autodetect)")
+	pflag.StringVarP(&format, "format", "f", "", "profile format such as yaml, json or toml (default: autodetect from the file extension, or yaml)")
 	pflag.BoolVar(&listProviders, "list-providers", false, "list available DNS providers then exit")
 
 	pflag.Usage = func() {
 		log.Printf("Usage:")
-		log.Printf("  %s [flags] <profile-path>\n", filepath.Base(os.Args[0]))
+		log.Printf("  %s [flags] <profile-path>...\n", filepath.Base(os.Args[0]))
+		for _, name := range sortedSubcommands() {
+			log.Printf("  %s\n", strings.TrimSpace(filepath.Base(os.Args[0])+" "+name+" "+subcommands[name].usage))
+		}
 		log.Printf("Flags:")
 		pflag.PrintDefaults()
 	}
 }
 
+// subcommand is a command that is run as `dnsmill <name> [args...]` instead
+// of applying profiles. Flags given before the name are passed on to the
+// subcommand, which rejects the ones it does not have.
+type subcommand struct {
+	// usage describes the arguments of the subcommand.
+	usage string
+	// run runs the subcommand with the arguments after its name.
+	run func(args []string) error
+}
+
+var subcommands = map[string]subcommand{
+	"export": {
+		usage: "--provider name --zone zone... [--format yaml|json|toml]",
+		run:   runExport,
+	},
+	"fmt": {
+		usage: "[--check] [--format yaml|json|toml] <profile-path>...",
+		run:   runFmt,
+	},
+	"import": {
+		usage: "--provider name [--origin zone] [--format yaml|json|toml] <zone-file>...",
+		run:   runImport,
+	},
+	"render": {
+		usage: "[--zone zone...] [--format yaml|json|toml] <profile-path>...",
+		run:   runRender,
+	},
+	"schema": {
+		usage: "",
+		run:   runSchema,
+	},
+}
+
+func sortedSubcommands() []string {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
 // Main is the entry point for the dnsmill command.
 func Main() {
+	if cmd, args, ok := findSubcommand(os.Args[1:]); ok {
+		if err := cmd.run(args); err != nil {
+			log.Fatalln(err)
+		}
+		os.Exit(0)
+	}
+
 	pflag.Parse()
 
 	if listProviders {
@@ -52,7 +105,7 @@ func Main() {
 		os.Exit(0)
 	}
 
-	if len(pflag.Args()) != 1 {
+	if len(pflag.Args()) == 0 {
 		pflag.Usage()
 		os.Exit(1)
 	}
@@ -80,12 +133,36 @@ func Main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	profilePath := pflag.Arg(0)
-	if !run(ctx, logger, profilePath) {
+	if !run(ctx, logger, pflag.Args()) {
 		os.Exit(1)
 	}
 }
 
+// findSubcommand looks for a subcommand name as the first argument that is
+// not a flag, so that flags may also be given before it. It returns the
+// subcommand and its arguments, which are the flags before the name followed
+// by the arguments after it.
+func findSubcommand(args []string) (subcommand, []string, bool) {
+	flags := pflag.NewFlagSet("dnsmill", pflag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.SetInterspersed(false)
+	flags.AddFlagSet(pflag.CommandLine)
+
+	// Errors are reported when the arguments are parsed again as profile
+	// paths.
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 || flags.ArgsLenAtDash() == 0 {
+		return subcommand{}, nil, false
+	}
+
+	cmd, ok := subcommands[flags.Arg(0)]
+	if !ok {
+		return subcommand{}, nil, false
+	}
+
+	leading := args[:len(args)-flags.NArg()]
+	return cmd, append(slices.Clip(leading), flags.Args()[1:]...), true
+}
+
 func printProviders() {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	for _, provider := range dnsmill.ListProviders() {
@@ -96,45 +173,14 @@ func printProviders() {
 	}
 }
 
-func run(ctx context.Context, logger *slog.Logger, profilePath string) bool {
-	logger = logger.With("profile", profilePath)
-
-	if format == "" {
-		switch strings.ToLower(filepath.Ext(profilePath)) {
-		case ".json":
-			format = "json"
-		case ".yaml", ".yml":
-			format = "yaml"
-		}
-	}
-
-	var parseProfile func(io.Reader) (*dnsmill.Profile, error)
-	switch format {
-	case "json":
-		parseProfile = dnsmill.ParseProfileAsJSON
-	case "yaml":
-		parseProfile = dnsmill.ParseProfileAsYAML
-	default:
-		logger.Error(
-			"unsupported profile format",
-			"format", format)
-	}
+func run(ctx context.Context, logger *slog.Logger, profilePaths []string) bool {
+	logger = logger.With("profile", strings.Join(profilePaths, ","))
 
-	f, err := os.Open(profilePath)
+	p, err := dnsmill.LoadProfile(format, profilePaths...)
 	if err != nil {
-		logger.Error("failed to open profile", tint.Err(err))
+		logger.Error("failed to load profile", tint.Err(err))
 		return false
 	}
-	defer f.Close()
-
-	p, err := parseProfile(f)
-	if err != nil {
-		logger.Error("failed to parse profile", tint.Err(err))
-		return false
-	}
-
-	// close file early, we don't need it anymore
-	f.Close()
 
 	if err := p.Apply(ctx, logger, dryRun); err != nil {
 		logger.Error("failed to apply profile", tint.Err(err))
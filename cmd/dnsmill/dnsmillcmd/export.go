@@ -0,0 +1,54 @@
+package dnsmillcmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/pflag"
+	"libdb.so/dnsmill"
+)
+
+// runExport prints a profile that declares the records currently in the
+// given zones of a provider.
+func runExport(args []string) error {
+	var (
+		providerName string
+		zones        []string
+		format       = "yaml"
+	)
+
+	flags := pflag.NewFlagSet("export", pflag.ExitOnError)
+	flags.StringVarP(&providerName, "provider", "p", providerName, "provider to read the zones from")
+	flags.StringArrayVarP(&zones, "zone", "z", zones, "zone to export, may be given multiple times")
+	flags.StringVarP(&format, "format", "f", format, "format of the exported profile")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if providerName == "" {
+		return errors.New("--provider is required")
+	}
+
+	domains := make([]dnsmill.Domain, len(zones))
+	for i, zone := range zones {
+		domains[i] = dnsmill.Domain(zone)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	profile, err := dnsmill.ExportProfile(ctx, providerName, domains...)
+	if err != nil {
+		return err
+	}
+
+	data, err := dnsmill.MarshalProfile(profile, format)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(data)
+	return err
+}
@@ -0,0 +1,85 @@
+package dnsmillcmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"libdb.so/dnsmill"
+)
+
+// runFmt rewrites the given profiles into their canonical form, printing the
+// paths of the profiles that changed.
+func runFmt(args []string) error {
+	var (
+		check  = false
+		format = ""
+	)
+
+	flags := pflag.NewFlagSet("fmt", pflag.ExitOnError)
+	flags.BoolVar(&check, "check", check, "only check that the profiles are formatted, exiting with an error if not")
+	flags.StringVarP(&format, "format", "f", format, "profile format such as yaml, json or toml (default: autodetect from the file extension, or yaml)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return errors.New("no profiles given")
+	}
+
+	var unformatted int
+	for _, path := range flags.Args() {
+		changed, err := formatProfileFile(path, format, !check)
+		if err != nil {
+			return err
+		}
+		if changed {
+			fmt.Println(path)
+			unformatted++
+		}
+	}
+
+	if check && unformatted > 0 {
+		return fmt.Errorf("%d profile(s) are not formatted", unformatted)
+	}
+	return nil
+}
+
+// formatProfileFile formats the profile file at the given path, writing it
+// back if write is true. It returns true if the file was not formatted.
+func formatProfileFile(path, format string, write bool) (bool, error) {
+	if format == "" {
+		format = "yaml"
+		if f, err := dnsmill.DetectProfileFormat(path); err == nil {
+			format = f.Name
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	formatted, err := dnsmill.FormatProfile(data, format)
+	if err != nil {
+		return false, fmt.Errorf("failed to format %q: %w", path, err)
+	}
+
+	if bytes.Equal(data, formatted) {
+		return false, nil
+	}
+
+	if write {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		if err := os.WriteFile(path, formatted, stat.Mode().Perm()); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
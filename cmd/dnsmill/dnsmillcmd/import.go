@@ -0,0 +1,68 @@
+package dnsmillcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"libdb.so/dnsmill"
+)
+
+// runImport prints a profile that declares the records of the given zone
+// files.
+func runImport(args []string) error {
+	var (
+		providerName string
+		origin       string
+		format       = "yaml"
+	)
+
+	flags := pflag.NewFlagSet("import", pflag.ExitOnError)
+	flags.StringVarP(&providerName, "provider", "p", providerName, "provider that manages the zones in the profile")
+	flags.StringVarP(&origin, "origin", "o", origin, "origin of zone files without an $ORIGIN directive")
+	flags.StringVarP(&format, "format", "f", format, "format of the imported profile")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if providerName == "" {
+		return errors.New("--provider is required")
+	}
+	if flags.NArg() == 0 {
+		return errors.New("no zone files given")
+	}
+
+	zones := make([]*dnsmill.Zone, flags.NArg())
+	for i, path := range flags.Args() {
+		zone, err := parseZoneFile(path, dnsmill.Domain(origin))
+		if err != nil {
+			return err
+		}
+		zones[i] = zone
+	}
+
+	profile := dnsmill.ImportZones(providerName, zones...)
+
+	data, err := dnsmill.MarshalProfile(profile, format)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func parseZoneFile(path string, origin dnsmill.Domain) (*dnsmill.Zone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zone, err := dnsmill.ParseZone(f, origin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", path, err)
+	}
+	return zone, nil
+}
@@ -0,0 +1,69 @@
+package dnsmillcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+
+	"github.com/spf13/pflag"
+	"libdb.so/dnsmill"
+)
+
+// runRender prints the zone files that the given profiles resolve to.
+func runRender(args []string) error {
+	var (
+		zones  []string
+		format = ""
+	)
+
+	flags := pflag.NewFlagSet("render", pflag.ExitOnError)
+	flags.StringArrayVarP(&zones, "zone", "z", zones, "only render the given zone, may be given multiple times")
+	flags.StringVarP(&format, "format", "f", format, "profile format such as yaml, json or toml (default: autodetect from the file extension, or yaml)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return errors.New("no profiles given")
+	}
+
+	p, err := dnsmill.LoadProfile(format, flags.Args()...)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	rendered, err := p.RenderZones(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, zone := range zones {
+		if !slices.ContainsFunc(rendered, func(z *dnsmill.Zone) bool { return string(z.Origin) == zone }) {
+			return fmt.Errorf("zone %q is not managed by the profile", zone)
+		}
+	}
+
+	var written int
+	for _, zone := range rendered {
+		if len(zones) > 0 && !slices.Contains(zones, string(zone.Origin)) {
+			continue
+		}
+		if written > 0 {
+			if _, err := os.Stdout.WriteString("\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := zone.WriteTo(os.Stdout); err != nil {
+			return err
+		}
+		written++
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package dnsmillcmd
+
+import (
+	"os"
+
+	"github.com/spf13/pflag"
+	"libdb.so/dnsmill"
+)
+
+// runSchema prints the JSON Schema of profiles, which includes the providers
+// that are bundled into the command.
+func runSchema(args []string) error {
+	flags := pflag.NewFlagSet("schema", pflag.ExitOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	schema, err := dnsmill.ProfileJSONSchema()
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(append(schema, '\n'))
+	return err
+}
@@ -8,6 +8,15 @@ import (
 // Config configures the behavior of the DNS tool.
 type Config struct {
 	DuplicatePolicy DuplicatePolicy `json:"duplicatePolicy,omitempty"`
+	// TTL is the default TTL for all records in the profile. It can be
+	// overridden per zone in [ProviderConfig] and per record in [Records].
+	// If zero, the default TTL of the DNS provider is used.
+	TTL TTL `json:"ttl,omitempty"`
+	// AllowUnknownFields disables strict parsing, which rejects keys that do
+	// not match any field, such as misspelled record types. Unknown keys are
+	// silently ignored if true. It only applies to the profile file that sets
+	// it, not to the files that it includes or is merged with.
+	AllowUnknownFields bool `json:"allowUnknownFields,omitempty"`
 }
 
 // DefaultConfig returns the default configuration for the DNS tool.
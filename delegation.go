@@ -0,0 +1,194 @@
+package dnsmill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// Delegation describes the NS records that delegate a subdomain to other
+// nameservers.
+//
+// When parsing, it may either parse a single nameserver, a list of
+// nameservers, or the actual [Delegation] instance itself.
+type Delegation struct {
+	// Nameservers lists the nameservers that the subdomain is delegated to.
+	Nameservers []Nameserver `json:"nameservers,omitempty"`
+	// FromProvider, if true, derives the nameservers from the provider that
+	// manages the delegated subdomain as its own zone in the same profile.
+	// The provider must be able to list records.
+	FromProvider bool `json:"fromProvider,omitempty"`
+}
+
+func (d *Delegation) UnmarshalJSON(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("invalid JSON: empty object")
+	}
+
+	switch data[0] {
+	case '"':
+		var ns Nameserver
+		if err := json.Unmarshal(data, &ns); err != nil {
+			return fmt.Errorf("failed to parse Delegation string: %w", err)
+		}
+		*d = Delegation{Nameservers: []Nameserver{ns}}
+
+	case '[':
+		var nss []Nameserver
+		if err := json.Unmarshal(data, &nss); err != nil {
+			return fmt.Errorf("failed to parse Delegation array: %w", err)
+		}
+		*d = Delegation{Nameservers: nss}
+
+	case '{':
+		type alias Delegation
+		var delegation alias
+		if err := json.Unmarshal(data, &delegation); err != nil {
+			return fmt.Errorf("failed to parse Delegation: %w", err)
+		}
+		*d = Delegation(delegation)
+
+	default:
+		return errors.New("invalid JSON: expected string, array, or object")
+	}
+
+	if len(d.Nameservers) == 0 && !d.FromProvider {
+		return errors.New("delegation must have at least one nameserver or use fromProvider")
+	}
+
+	return nil
+}
+
+// MarshalJSON marshals the delegation as its nameservers, or a single
+// nameserver, unless it uses fromProvider.
+func (d Delegation) MarshalJSON() ([]byte, error) {
+	switch {
+	case d.FromProvider:
+		type alias Delegation
+		return json.Marshal(alias(d))
+	case len(d.Nameservers) == 1:
+		return json.Marshal(d.Nameservers[0])
+	default:
+		return json.Marshal(d.Nameservers)
+	}
+}
+
+// Convert converts the delegation of the given subdomain into a list of NS
+// [libdns.Record]s.
+func (d *Delegation) Convert(subdomain string, ttl TTL) []libdns.Record {
+	records := make([]libdns.Record, len(d.Nameservers))
+	for i, ns := range d.Nameservers {
+		records[i] = libdns.Record{
+			Type:  "NS",
+			Name:  subdomain,
+			Value: ns.Name,
+			TTL:   ttl.Duration(),
+		}
+	}
+	return records
+}
+
+// ConvertGlue converts the glue addresses of the nameservers within the
+// delegated domain into a list of A and AAAA [libdns.Record]s relative to
+// the given root domain.
+func (d *Delegation) ConvertGlue(ctx context.Context, domain, rootDomain Domain, ttl TTL) ([]libdns.Record, error) {
+	var records []libdns.Record
+
+	for _, ns := range d.Nameservers {
+		nsDomain := ns.Domain()
+
+		if _, inZone := nsDomain.SubdomainOf(domain); !inZone {
+			if ns.Addresses != nil {
+				return nil, fmt.Errorf(
+					"nameserver %q has glue addresses but is not within %q",
+					ns.Name, domain)
+			}
+			continue
+		}
+
+		if ns.Addresses == nil {
+			return nil, fmt.Errorf(
+				"nameserver %q is within %q and requires glue addresses",
+				ns.Name, domain)
+		}
+
+		nsSubdomain, ok := nsDomain.SubdomainOf(rootDomain)
+		if !ok {
+			return nil, fmt.Errorf("%q is not a subdomain of %q", nsDomain, rootDomain)
+		}
+
+		glue := Records{Hosts: ns.Addresses, TTL: ttl}
+		recs, err := glue.Convert(ctx, nsSubdomain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert glue for nameserver %q: %w", ns.Name, err)
+		}
+
+		records = append(records, recs...)
+	}
+
+	return records, nil
+}
+
+// Nameserver describes a single nameserver in a [Delegation].
+//
+// When parsing, it may either parse a single nameserver hostname or the
+// actual [Nameserver] instance itself.
+type Nameserver struct {
+	// Name is the hostname of the nameserver.
+	Name string `json:"name"`
+	// Addresses lists the host addresses of the nameserver. They are used to
+	// create glue records and are required if the nameserver is within the
+	// delegated subdomain.
+	Addresses *HostAddresses `json:"addresses,omitempty"`
+}
+
+func (n *Nameserver) UnmarshalJSON(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("invalid JSON: empty object")
+	}
+
+	switch data[0] {
+	case '"':
+		var name string
+		if err := json.Unmarshal(data, &name); err != nil {
+			return fmt.Errorf("failed to parse Nameserver string: %w", err)
+		}
+		*n = Nameserver{Name: name}
+
+	case '{':
+		type alias Nameserver
+		var ns alias
+		if err := json.Unmarshal(data, &ns); err != nil {
+			return fmt.Errorf("failed to parse Nameserver: %w", err)
+		}
+		*n = Nameserver(ns)
+
+	default:
+		return errors.New("invalid JSON: expected string or object")
+	}
+
+	if n.Name == "" {
+		return errors.New("nameserver must have a name")
+	}
+
+	return nil
+}
+
+// MarshalJSON marshals the nameserver as its name if it has no addresses.
+func (n Nameserver) MarshalJSON() ([]byte, error) {
+	if n.Addresses == nil {
+		return json.Marshal(n.Name)
+	}
+	type alias Nameserver
+	return json.Marshal(alias(n))
+}
+
+// Domain returns the nameserver's hostname as a [Domain] without the
+// trailing dot.
+func (n Nameserver) Domain() Domain {
+	return Domain(strings.TrimSuffix(n.Name, "."))
+}
@@ -0,0 +1,179 @@
+package dnsmill
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// DKIMKeys represents a list of DKIM keys, each published under its own
+// selector.
+//
+// When parsing, it may either parse a single DKIM key or a list of DKIM keys.
+type DKIMKeys []DKIM
+
+func (k *DKIMKeys) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var items []DKIM
+	if bytes.HasPrefix(data, []byte{'['}) {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("failed to parse DKIMKeys array: %w", err)
+		}
+	} else {
+		var item DKIM
+		if err := json.Unmarshal(data, &item); err != nil {
+			return fmt.Errorf("failed to parse DKIMKeys object: %w", err)
+		}
+		items = []DKIM{item}
+	}
+
+	*k = items
+	return nil
+}
+
+// Convert reads the key files and converts them into a list of TXT
+// [libdns.Record]s at `<selector>._domainkey.<subdomain>`.
+func (k DKIMKeys) Convert(subdomain string, ttl TTL) ([]libdns.Record, error) {
+	records := make([]libdns.Record, 0, len(k))
+	for _, key := range k {
+		value, err := key.TXT()
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive DKIM record for selector %q: %w", key.Selector, err)
+		}
+		records = append(records, libdns.Record{
+			Type:  "TXT",
+			Name:  prefixSubdomain(key.Selector+"._domainkey", subdomain),
+			Value: value,
+			TTL:   ttl.Duration(),
+		})
+	}
+	return records, nil
+}
+
+// DKIM describes a DKIM key whose public key is published as a TXT record.
+// The key is read from the file every time the records are converted, so
+// rotated keys are picked up on the next run.
+type DKIM struct {
+	// Selector is the DKIM selector of the key.
+	Selector string `json:"selector"`
+	// File is the path to a PEM file containing either the private key or
+	// the public key. RSA and Ed25519 keys are supported.
+	File string `json:"file"`
+}
+
+func (d *DKIM) UnmarshalJSON(data []byte) error {
+	type alias DKIM
+	var dkim alias
+	if err := json.Unmarshal(data, &dkim); err != nil {
+		return fmt.Errorf("failed to parse DKIM: %w", err)
+	}
+
+	if dkim.Selector == "" {
+		return errors.New("DKIM key must have a selector")
+	}
+	if dkim.File == "" {
+		return fmt.Errorf("DKIM key %q must have a file", dkim.Selector)
+	}
+
+	*d = DKIM(dkim)
+	return nil
+}
+
+// Validate validates the selector of the DKIM key. It does not read the file.
+// It is called once the profile is expanded, since the selector may be
+// interpolated.
+func (d DKIM) Validate() error {
+	if strings.ContainsAny(d.Selector, " \t") ||
+		strings.HasPrefix(d.Selector, ".") ||
+		strings.HasSuffix(d.Selector, ".") {
+		return fmt.Errorf("invalid DKIM selector %q", d.Selector)
+	}
+	return nil
+}
+
+// TXT reads the key file and returns the DKIM TXT record value, such as
+// `v=DKIM1; k=rsa; p=MIIBIjANBgkq...`.
+//
+// The value of an RSA-2048 key is longer than the 255 bytes that a single
+// character string can hold. Like all TXT values, it is returned as one
+// string and split into character strings where the record is written out:
+// by the DNS provider when the profile is applied, and by [Zone.WriteTo]
+// when it is rendered.
+func (d DKIM) TXT() (string, error) {
+	b, err := os.ReadFile(d.File)
+	if err != nil {
+		return "", fmt.Errorf("failed to read DKIM key file: %w", err)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return "", fmt.Errorf("no PEM data found in %q", d.File)
+	}
+
+	var publicKey crypto.PublicKey
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse private key in %q: %w", d.File, err)
+		}
+		signer, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return "", fmt.Errorf("unsupported private key type %T in %q", privateKey, d.File)
+		}
+		publicKey = signer.Public()
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse RSA private key in %q: %w", d.File, err)
+		}
+		publicKey = privateKey.Public()
+	case "PUBLIC KEY":
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse public key in %q: %w", d.File, err)
+		}
+	case "RSA PUBLIC KEY":
+		publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse RSA public key in %q: %w", d.File, err)
+		}
+	default:
+		return "", fmt.Errorf("unsupported PEM block %q in %q", block.Type, d.File)
+	}
+
+	var keyType string
+	var keyData []byte
+
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		keyType = "rsa"
+		keyData, err = x509.MarshalPKIXPublicKey(publicKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal RSA public key: %w", err)
+		}
+	case ed25519.PublicKey:
+		// RFC 8463 uses the raw public key rather than the PKIX form.
+		keyType = "ed25519"
+		keyData = publicKey
+	default:
+		return "", fmt.Errorf("unsupported key type %T in %q, only RSA and Ed25519 are supported", publicKey, d.File)
+	}
+
+	return fmt.Sprintf("v=DKIM1; k=%s; p=%s", keyType, base64.StdEncoding.EncodeToString(keyData)), nil
+}
@@ -0,0 +1,215 @@
+package dnsmill
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// DNSSDServicesName is the name under which the service types of a domain are
+// enumerated, as described in RFC 6763 section 9.
+const DNSSDServicesName = "_services._dns-sd._udp"
+
+// DNSSDServices represents a list of DNS-SD service instances advertised
+// within a domain.
+//
+// When parsing, it may either parse a single service instance or a list of
+// service instances.
+type DNSSDServices []DNSSD
+
+func (s *DNSSDServices) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var items []DNSSD
+	if bytes.HasPrefix(data, []byte{'['}) {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("failed to parse DNSSDServices array: %w", err)
+		}
+	} else {
+		var item DNSSD
+		if err := json.Unmarshal(data, &item); err != nil {
+			return fmt.Errorf("failed to parse DNSSDServices object: %w", err)
+		}
+		items = []DNSSD{item}
+	}
+
+	*s = items
+	return nil
+}
+
+// Convert expands the service instances advertised within the given domain
+// into the PTR, SRV and TXT [libdns.Record]s required by RFC 6763. The
+// subdomain is the name of the domain relative to its zone. Instance names are
+// kept as they are in the records, since providers take names as plain text.
+// [Profile.RenderZones] escapes them for zone files.
+func (s DNSSDServices) Convert(domain Domain, subdomain string, ttl TTL) []libdns.Record {
+	records := make([]libdns.Record, 0, len(s)*3+1)
+	enumerated := make(map[string]bool, len(s))
+
+	for _, service := range s {
+		if !enumerated[service.Service] {
+			enumerated[service.Service] = true
+			records = append(records, libdns.Record{
+				Type:  "PTR",
+				Name:  prefixSubdomain(DNSSDServicesName, subdomain),
+				Value: service.Service + "." + string(domain) + ".",
+				TTL:   ttl.Duration(),
+			})
+		}
+
+		instance := service.Instance + "." + service.Service
+		records = append(records,
+			libdns.Record{
+				Type:  "PTR",
+				Name:  prefixSubdomain(service.Service, subdomain),
+				Value: instance + "." + string(domain) + ".",
+				TTL:   ttl.Duration(),
+			},
+			libdns.Record{
+				Type:     "SRV",
+				Name:     prefixSubdomain(instance, subdomain),
+				Value:    fmt.Sprintf("%d %s", service.Port, service.Target),
+				Priority: uint(service.Priority),
+				Weight:   uint(service.Weight),
+				TTL:      ttl.Duration(),
+			},
+			libdns.Record{
+				Type:  "TXT",
+				Name:  prefixSubdomain(instance, subdomain),
+				Value: service.TXTValue(),
+				TTL:   ttl.Duration(),
+			},
+		)
+	}
+
+	return records
+}
+
+// DNSSD describes a single DNS-SD service instance as described in RFC 6763,
+// such as a printer or a file server.
+type DNSSD struct {
+	// Instance is the user-friendly name of the service instance, such as
+	// "Office Printer". It may contain spaces and dots.
+	Instance string `json:"instance"`
+	// Service is the service type, such as "_ipp._tcp".
+	Service string `json:"service"`
+	// Port is the port that the service listens on.
+	Port uint16 `json:"port"`
+	// Target is the hostname of the machine providing the service.
+	Target string `json:"target"`
+	// Priority is the priority of the SRV record.
+	Priority uint16 `json:"priority,omitempty"`
+	// Weight is the weight of the SRV record.
+	Weight uint16 `json:"weight,omitempty"`
+	// TXT contains the key/value pairs that are published in the TXT record of
+	// the service instance. Each pair is its own character string, as RFC 6763
+	// requires, sorted by key.
+	TXT map[string]string `json:"txt,omitempty"`
+}
+
+var dnssdServiceRegex = regexp.MustCompile(`^_[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?\._(tcp|udp)$`)
+
+func (d *DNSSD) UnmarshalJSON(data []byte) error {
+	type alias DNSSD
+	var dnssd alias
+	if err := json.Unmarshal(data, &dnssd); err != nil {
+		return fmt.Errorf("failed to parse DNSSD: %w", err)
+	}
+
+	if dnssd.Instance == "" {
+		return errors.New("DNS-SD service must have an instance name")
+	}
+	if len(dnssd.Instance) > 63 {
+		return fmt.Errorf("DNS-SD instance name %q is longer than 63 bytes", dnssd.Instance)
+	}
+	if !dnssdServiceRegex.MatchString(dnssd.Service) {
+		return fmt.Errorf("invalid DNS-SD service type %q, expected a form like _ipp._tcp", dnssd.Service)
+	}
+	if dnssd.Port == 0 {
+		return fmt.Errorf("DNS-SD service %q must have a port", dnssd.Instance)
+	}
+	if dnssd.Target == "" {
+		return fmt.Errorf("DNS-SD service %q must have a target", dnssd.Instance)
+	}
+	for key, value := range dnssd.TXT {
+		if key == "" || strings.Contains(key, "=") {
+			return fmt.Errorf("invalid TXT key %q for DNS-SD service %q", key, dnssd.Instance)
+		}
+		if len(key)+1+len(value) > maxZoneStringLength {
+			return fmt.Errorf("TXT key/value pair %q for DNS-SD service %q is longer than %d bytes",
+				key, dnssd.Instance, maxZoneStringLength)
+		}
+	}
+
+	*d = DNSSD(dnssd)
+	return nil
+}
+
+// TXTValue returns the TXT record value of the service instance. Each
+// key/value pair must be its own character string, but libdns records hold a
+// TXT value as a single string, so the pairs are returned as quoted character
+// strings in presentation format, such as `"rp=printers/office" "note=2nd
+// floor"`, sorted by key. RFC 6763 requires a TXT record even if there are no
+// key/value pairs, in which case a single empty character string is returned.
+func (d DNSSD) TXTValue() string {
+	if len(d.TXT) == 0 {
+		return `""`
+	}
+	strs := make([]string, 0, len(d.TXT))
+	for _, key := range sortedKeys(d.TXT) {
+		strs = append(strs, quoteZoneString(key+"="+d.TXT[key]))
+	}
+	return strings.Join(strs, " ")
+}
+
+// escapeInstances escapes the instance names within the records converted by
+// [DNSSDServices.Convert] for the given domain, so that each instance name is
+// written as a single label in presentation format.
+func (s DNSSDServices) escapeInstances(domain Domain, subdomain string, records []libdns.Record) {
+	for _, service := range s {
+		plain := service.Instance + "." + service.Service
+		escaped := escapeDNSLabel(service.Instance) + "." + service.Service
+		if plain == escaped {
+			continue
+		}
+
+		plainName := prefixSubdomain(plain, subdomain)
+		plainValue := plain + "." + string(domain) + "."
+		for i, record := range records {
+			switch {
+			case (record.Type == "SRV" || record.Type == "TXT") && record.Name == plainName:
+				records[i].Name = prefixSubdomain(escaped, subdomain)
+			case record.Type == "PTR" && record.Value == plainValue:
+				records[i].Value = escaped + "." + string(domain) + "."
+			}
+		}
+	}
+}
+
+// escapeDNSLabel escapes a string so that it can be used as a single DNS
+// label in presentation format as described in RFC 1035 section 5.1. Dots,
+// spaces and other special characters are escaped with a backslash, and
+// non-printable bytes, including those of non-ASCII characters, are escaped
+// as `\DDD`.
+func escapeDNSLabel(label string) string {
+	var b strings.Builder
+	for i := 0; i < len(label); i++ {
+		switch c := label[i]; {
+		case strings.IndexByte(`. \"();@$`, c) != -1:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < '!' || c > '~':
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -2,9 +2,12 @@ package dnsmill
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
+	"slices"
 	"strings"
 
 	"github.com/libdns/libdns"
@@ -29,6 +32,16 @@ func (d Domain) SubdomainOf(rootDomain Domain) (string, bool) {
 	return "", false
 }
 
+// prefixSubdomain prepends the given labels to the subdomain, which is relative
+// to the root domain. The subdomain may be "" or "@" for the root domain
+// itself.
+func prefixSubdomain(prefix, subdomain string) string {
+	if subdomain == "" || subdomain == "@" {
+		return prefix
+	}
+	return prefix + "." + subdomain
+}
+
 // Domains represents a list of domain names.
 //
 // When parsing, it may either parse a single domain name or a list of domain
@@ -57,12 +70,44 @@ func (d *Domains) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalJSON marshals a single domain name as a string and multiple domain
+// names as an array.
+func (d Domains) MarshalJSON() ([]byte, error) {
+	if len(d) == 1 {
+		return json.Marshal(d[0])
+	}
+	return json.Marshal([]Domain(d))
+}
+
 // DomainRecords maps subdomains to their DNS records.
 type DomainRecords map[Domain]Records
 
+// sortedDomains returns the domains in sorted order.
+func (r DomainRecords) sortedDomains() []Domain {
+	return sortedKeys(r)
+}
+
+// sortedKeys returns the keys of the map in sorted order.
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
 // Convert converts the subdomain records into a list of [libdns.Record]s.
 func (r DomainRecords) Convert(ctx context.Context, rootDomain Domain) ([]libdns.Record, error) {
-	records := make([]libdns.Record, 0, len(r))
+	records, err := r.convert(ctx, rootDomain, zoneExtensions{}, nil)
+	return plainRecords(records), err
+}
+
+// convert is like Convert, but it also attaches the given extensions to the
+// records, and adds the addresses that the hosts of records with PTR enabled
+// resolved to into ptrAddrs if it is not nil.
+func (r DomainRecords) convert(ctx context.Context, rootDomain Domain, extensions zoneExtensions, ptrAddrs map[Domain][]net.IPAddr) ([]ExtendedRecord, error) {
+	records := make([]ExtendedRecord, 0, len(r))
 
 	for domain, rec := range r {
 		subdomain, ok := domain.SubdomainOf(rootDomain)
@@ -70,12 +115,38 @@ func (r DomainRecords) Convert(ctx context.Context, rootDomain Domain) ([]libdns
 			return nil, fmt.Errorf("%q is not a subdomain of %q", domain, rootDomain)
 		}
 
-		recs, err := rec.Convert(ctx, subdomain)
+		recs, addrs, err := rec.convert(ctx, subdomain)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert records for %q: %w", domain, err)
 		}
+		if rec.PTR && ptrAddrs != nil {
+			ptrAddrs[domain] = addrs
+		}
+
+		records = append(records, extensions.extend(domain, cmp.Or(subdomain, "@"), recs)...)
+
+		// The records below are derived from the records of the domain, so
+		// they never have its extensions.
+
+		if rec.NS != nil {
+			glue, err := rec.NS.ConvertGlue(ctx, domain, rootDomain, rec.TTL)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert glue records for %q: %w", domain, err)
+			}
+			records = append(records, withoutExtensions(glue)...)
+		}
 
-		records = append(records, recs...)
+		if rec.DNSSD != nil {
+			records = append(records, withoutExtensions(rec.DNSSD.Convert(domain, subdomain, rec.TTL))...)
+		}
+
+		if rec.Preset != nil {
+			recs, err := rec.Preset.Convert(domain, subdomain, rec.TTL)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert presets for %q: %w", domain, err)
+			}
+			records = append(records, withoutExtensions(recs)...)
+		}
 	}
 
 	return records, nil
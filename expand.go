@@ -0,0 +1,198 @@
+package dnsmill
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// interpolationRegex matches `${source:name}` interpolations as well as the
+// `$${` escape sequence.
+var interpolationRegex = regexp.MustCompile(`\$\$\{|\$\{([a-z]+):([^}]*)\}`)
+
+// Expand expands the interpolations within the string values of the profile,
+// including domain names. The following interpolations are supported:
+//
+//   - `${var:name}` is replaced with the variable from [Profile.Vars].
+//   - `${env:NAME}` is replaced with the environment variable.
+//   - `${file:path}` is replaced with the contents of the file, excluding the
+//     trailing newline. [LoadProfile] resolves relative paths from the
+//     directory of the file that declares the interpolation. Otherwise, they
+//     are resolved from the working directory.
+//
+// `$${` can be used to write a literal `${`. Variables may use env and file
+// interpolations but not other variables. Only string values are expanded, so
+// numbers such as ports cannot be interpolated. All undefined variables are
+// reported at once using [errors.Join].
+//
+// Expand is called by [LoadProfile] and the ParseProfile functions before the
+// profile is validated.
+func (p *Profile) Expand() error {
+	vars := make(map[string]string, len(p.Vars))
+	varsExpander := expander{}
+	for _, name := range sortedKeys(p.Vars) {
+		vars[name] = varsExpander.expandString(p.Vars[name])
+	}
+	if len(varsExpander.errs) > 0 {
+		return fmt.Errorf("failed to expand vars: %w", errors.Join(varsExpander.errs...))
+	}
+
+	e := expander{vars: vars}
+
+	v := reflect.ValueOf(p).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Name == "Vars" {
+			continue
+		}
+		e.expand(v.Field(i))
+	}
+
+	return errors.Join(e.errs...)
+}
+
+// resolveFilePaths rewrites the relative paths of the file interpolations
+// within the profile to be relative to dir instead, so that they still refer to
+// the same files once the profile is merged with profiles from other
+// directories. Other interpolations are left as they are.
+func (p *Profile) resolveFilePaths(dir string) {
+	e := expander{dir: dir}
+	e.expand(reflect.ValueOf(p).Elem())
+}
+
+type expander struct {
+	// vars is nil if variables cannot be used.
+	vars map[string]string
+	// dir is set if the expander only resolves the relative paths of file
+	// interpolations from dir instead of expanding anything.
+	dir  string
+	errs []error
+}
+
+func (e *expander) expandString(str string) string {
+	if !strings.Contains(str, "${") {
+		return str
+	}
+
+	return interpolationRegex.ReplaceAllStringFunc(str, func(match string) string {
+		if match == "$${" {
+			if e.dir != "" {
+				return match
+			}
+			return "${"
+		}
+
+		parts := interpolationRegex.FindStringSubmatch(match)
+		source, name := parts[1], parts[2]
+
+		if e.dir != "" {
+			if source == "file" && !filepath.IsAbs(name) {
+				return "${file:" + filepath.Join(e.dir, name) + "}"
+			}
+			return match
+		}
+
+		switch source {
+		case "var":
+			if e.vars == nil {
+				e.errs = append(e.errs, fmt.Errorf("variable %q cannot be used within vars", name))
+				return match
+			}
+			v, ok := e.vars[name]
+			if !ok {
+				e.errs = append(e.errs, fmt.Errorf("undefined variable %q", name))
+				return match
+			}
+			return v
+		case "env":
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				e.errs = append(e.errs, fmt.Errorf("undefined environment variable %q", name))
+				return match
+			}
+			return v
+		case "file":
+			b, err := os.ReadFile(name)
+			if err != nil {
+				e.errs = append(e.errs, fmt.Errorf("failed to read file for interpolation: %w", err))
+				return match
+			}
+			return strings.TrimSuffix(string(b), "\n")
+		default:
+			e.errs = append(e.errs, fmt.Errorf("unknown interpolation source %q in %q", source, match))
+			return match
+		}
+	})
+}
+
+func (e *expander) expand(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(e.expandString(v.String()))
+		}
+
+	case reflect.Pointer:
+		if !v.IsNil() {
+			e.expand(v.Elem())
+		}
+
+	case reflect.Interface:
+		if !v.IsNil() && v.CanSet() {
+			// Values within interfaces are not addressable, so expand a copy.
+			elem := reflect.New(v.Elem().Type()).Elem()
+			elem.Set(v.Elem())
+			e.expand(elem)
+			v.Set(elem)
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				e.expand(v.Field(i))
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			e.expand(v.Index(i))
+		}
+
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+
+		// Expand the keys in order so that errors are reported in order.
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+		})
+
+		expanded := reflect.MakeMapWithSize(v.Type(), len(keys))
+		for _, key := range keys {
+			// Map keys and values are not addressable, so expand copies.
+			newKey := reflect.New(key.Type()).Elem()
+			newKey.Set(key)
+			e.expand(newKey)
+
+			newValue := reflect.New(v.Type().Elem()).Elem()
+			newValue.Set(v.MapIndex(key))
+			e.expand(newValue)
+
+			if expanded.MapIndex(newKey).IsValid() {
+				e.errs = append(e.errs, fmt.Errorf("%q expands to a duplicate key %q", key, newKey))
+				continue
+			}
+			expanded.SetMapIndex(newKey, newValue)
+		}
+
+		if v.CanSet() {
+			v.Set(expanded)
+		}
+	}
+}
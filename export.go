@@ -0,0 +1,251 @@
+package dnsmill
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+var errNoZones = errors.New("no zones given to export")
+
+// ExportProfile reads the records of the given zones from the provider and
+// returns a profile that declares them. The provider must be able to list
+// records. See [ExportRecords] for how the records are converted.
+func ExportProfile(ctx context.Context, providerName string, zones ...Domain) (*Profile, error) {
+	if len(zones) == 0 {
+		return nil, errNoZones
+	}
+
+	factory, err := getProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := factory.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create provider %q: %w", providerName, err)
+	}
+
+	getter, ok := provider.(libdns.RecordGetter)
+	if !ok {
+		return nil, fmt.Errorf("provider %q cannot list records", providerName)
+	}
+
+	exported := make([]*Zone, len(zones))
+	for i, zone := range zones {
+		records, err := getter.GetRecords(ctx, string(zone))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get records of %q: %w", zone, err)
+		}
+		exported[i] = &Zone{Origin: zone, Records: records}
+	}
+
+	return ImportZones(providerName, exported...), nil
+}
+
+// ImportZones returns a profile that declares the records of the given zones,
+// which are all managed by the given provider. See [ExportRecords] for how
+// the records are converted.
+func ImportZones(providerName string, zones ...*Zone) *Profile {
+	p := NewProfile()
+	p.Records = DomainRecords{}
+
+	origins := make([]Domain, len(zones))
+	zoneTTLs := make([]TTL, len(zones))
+	for i, zone := range zones {
+		domainRecords, ttl := ExportRecords(zone.Origin, zone.Records)
+		for domain, records := range domainRecords {
+			p.Records[domain] = records
+		}
+		origins[i] = zone.Origin
+		zoneTTLs[i] = ttl
+	}
+
+	// The zones share the provider, so its TTL is only set if all zones have
+	// the same TTL. Otherwise, the TTL is set on each domain instead.
+	config := ProviderConfig{Zones: origins}
+	if len(zoneTTLs) > 0 && !slices.ContainsFunc(zoneTTLs, func(ttl TTL) bool { return ttl != zoneTTLs[0] }) {
+		config.TTL = zoneTTLs[0]
+	} else {
+		for domain, records := range p.Records {
+			zone := exportZoneOf(origins, domain)
+			records.TTL = records.TTL.Or(zoneTTLs[slices.Index(origins, zone)])
+			p.Records[domain] = records
+		}
+	}
+	p.Providers = map[string]ProviderConfig{providerName: config}
+
+	return p
+}
+
+// exportZoneOf returns the most specific of the zones that contains the
+// domain.
+func exportZoneOf(zones []Domain, domain Domain) Domain {
+	var zone Domain
+	for _, z := range zones {
+		if _, ok := domain.SubdomainOf(z); ok && len(z) > len(zone) {
+			zone = z
+		}
+	}
+	return zone
+}
+
+// ExportRecords converts the records of the given zone into the records of
+// each domain within the zone, using the shortest forms that [Records]
+// supports:
+//
+//   - A and AAAA records become hosts.
+//   - A CNAME record that is the only record of its name becomes cname, or
+//     alias at the zone apex.
+//   - NS records that are the only records of their name become ns. NS
+//     records at the zone apex are managed by the provider and are skipped,
+//     as are SOA records.
+//   - All other records are preserved as raw records.
+//
+// The most common TTL of the records is returned as the TTL of the zone, and
+// only TTLs that differ from it are set on the records.
+func ExportRecords(zone Domain, records []libdns.Record) (DomainRecords, TTL) {
+	byDomain := make(map[Domain][]libdns.Record)
+	var ttls []time.Duration
+	for _, record := range records {
+		domain := Domain(strings.TrimSuffix(libdns.AbsoluteName(record.Name, string(zone)), "."))
+		if record.Type == "SOA" || (record.Type == "NS" && domain == zone) {
+			continue
+		}
+		byDomain[domain] = append(byDomain[domain], record)
+		ttls = append(ttls, record.TTL)
+	}
+
+	zoneTTL := mostCommonTTL(ttls)
+
+	exported := make(DomainRecords, len(byDomain))
+	glue := make(map[Domain]bool)
+	for domain, records := range byDomain {
+		r := exportDomainRecords(domain == zone, records, zoneTTL)
+		if r.NS != nil {
+			// Nameservers within the delegated domain take their glue
+			// addresses from the zone, which are then created from the
+			// delegation instead.
+			for i, ns := range r.NS.Nameservers {
+				if sub, ok := ns.Domain().SubdomainOf(domain); !ok || sub == "" {
+					continue
+				}
+				if addrs := exportGlue(byDomain[ns.Domain()]); addrs != nil {
+					r.NS.Nameservers[i].Addresses = addrs
+					glue[ns.Domain()] = true
+				}
+			}
+		}
+		exported[domain] = r
+	}
+	for domain := range glue {
+		delete(exported, domain)
+	}
+	return exported, zoneTTL
+}
+
+// exportGlue returns the addresses of the records if they are all A and AAAA
+// records, or nil otherwise.
+func exportGlue(records []libdns.Record) *HostAddresses {
+	if len(records) == 0 {
+		return nil
+	}
+	addrs := make(HostAddresses, len(records))
+	for i, record := range records {
+		if record.Type != "A" && record.Type != "AAAA" {
+			return nil
+		}
+		addrs[i] = HostAddress{Address: record.Value}
+	}
+	slices.SortFunc(addrs, func(a, b HostAddress) int { return cmp.Compare(a.Address, b.Address) })
+	return &addrs
+}
+
+func exportDomainRecords(apex bool, records []libdns.Record, zoneTTL TTL) Records {
+	slices.SortFunc(records, func(a, b libdns.Record) int {
+		return cmp.Or(
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.Priority, b.Priority),
+			cmp.Compare(a.Value, b.Value),
+		)
+	})
+
+	ttls := make([]time.Duration, len(records))
+	for i, record := range records {
+		ttls[i] = record.TTL
+	}
+
+	var r Records
+	ttl := mostCommonTTL(ttls)
+	if ttl != zoneTTL {
+		r.TTL = ttl
+	}
+
+	onlyType := func(recordType string) bool {
+		return !slices.ContainsFunc(records, func(record libdns.Record) bool {
+			return record.Type != recordType || TTL(record.TTL) != ttl
+		})
+	}
+
+	switch {
+	case len(records) == 1 && records[0].Type == "CNAME" && apex:
+		r.Alias = &records[0].Value
+		return r
+	case len(records) == 1 && records[0].Type == "CNAME":
+		r.CNAME = &records[0].Value
+		return r
+	case onlyType("NS"):
+		r.NS = &Delegation{}
+		for _, record := range records {
+			r.NS.Nameservers = append(r.NS.Nameservers, Nameserver{Name: record.Value})
+		}
+		return r
+	}
+
+	for _, record := range records {
+		if (record.Type == "A" || record.Type == "AAAA") && TTL(record.TTL) == ttl {
+			if r.Hosts == nil {
+				r.Hosts = &HostAddresses{}
+			}
+			*r.Hosts = append(*r.Hosts, HostAddress{Address: record.Value})
+			continue
+		}
+
+		raw := RawRecord{
+			Type:     record.Type,
+			Value:    record.Value,
+			Priority: record.Priority,
+			Weight:   record.Weight,
+			Target:   record.Target,
+		}
+		if TTL(record.TTL) != ttl {
+			raw.TTL = TTL(record.TTL)
+		}
+		r.Raw = append(r.Raw, raw)
+	}
+
+	return r
+}
+
+// mostCommonTTL returns the most common of the TTLs, preferring the lowest
+// one if there are multiple.
+func mostCommonTTL(ttls []time.Duration) TTL {
+	counts := make(map[time.Duration]int, len(ttls))
+	for _, ttl := range ttls {
+		counts[ttl]++
+	}
+
+	var common time.Duration
+	for ttl, count := range counts {
+		if count > counts[common] || (count == counts[common] && ttl < common) {
+			common = ttl
+		}
+	}
+	return TTL(common)
+}
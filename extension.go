@@ -0,0 +1,178 @@
+package dnsmill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// ProviderExtensions contains the provider-specific options of records, keyed
+// by the name of the provider. Only the options of the provider that manages
+// the records are applied, so records can be moved between providers without
+// losing their options. Options of other providers are still validated.
+type ProviderExtensions map[string]any
+
+// Validate validates the options of each provider. The providers must be
+// registered and support extensions. The types are the record types that the
+// records of the domain are converted into at the domain itself, at least one
+// of which must be supported by the extensions of each provider.
+func (e ProviderExtensions) Validate(types []string) error {
+	for _, name := range sortedKeys(e) {
+		ext, err := e.parse(name)
+		if err != nil {
+			return err
+		}
+		if ext == nil {
+			continue
+		}
+
+		factory, _ := getProvider(name)
+		if !slices.ContainsFunc(types, factory.extendsType) {
+			return fmt.Errorf("%s extensions only apply to %s records, but there are none",
+				name, strings.Join(factory.ExtensionTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// parse parses the options of the given provider using its
+// [ProviderFactory.ParseExtension]. It returns nil if there are no options
+// for the provider.
+func (e ProviderExtensions) parse(providerName string) (any, error) {
+	v, ok := e[providerName]
+	if !ok || v == nil {
+		return nil, nil
+	}
+
+	factory, err := getProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	if factory.ParseExtension == nil {
+		return nil, fmt.Errorf("provider %q does not support extensions", providerName)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s extension: %w", providerName, err)
+	}
+
+	ext, err := factory.ParseExtension(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s extension: %w", providerName, err)
+	}
+	return ext, nil
+}
+
+// ExtendedRecord is a [libdns.Record] with the provider-specific options of
+// the records that it was converted from.
+type ExtendedRecord struct {
+	libdns.Record
+	// Extension is the value returned by [ProviderFactory.ParseExtension].
+	Extension any
+}
+
+// RecordExtender is implemented by providers that support provider-specific
+// options of records. Its factory must set [ProviderFactory.ParseExtension].
+//
+// Records with options are created or updated together with their options
+// using RecordExtender instead of [libdns.RecordAppender] and
+// [libdns.RecordSetter], so that they never exist without them.
+type RecordExtender interface {
+	// AppendExtendedRecords creates the records with their options like
+	// [libdns.RecordAppender.AppendRecords]. It returns the records that were
+	// created.
+	AppendExtendedRecords(ctx context.Context, zone string, records []ExtendedRecord) ([]libdns.Record, error)
+	// SetExtendedRecords creates or updates the records with their options
+	// like [libdns.RecordSetter.SetRecords]. It returns the records that were
+	// created or updated.
+	SetExtendedRecords(ctx context.Context, zone string, records []ExtendedRecord) ([]libdns.Record, error)
+}
+
+// zoneExtensions contains the parsed extensions of the records within a zone
+// for the provider that manages the zone.
+type zoneExtensions struct {
+	// factory is the factory of the provider.
+	factory ProviderFactory
+	// domains maps the domains to their extensions.
+	domains map[Domain]any
+}
+
+// zoneExtensions parses the extensions of the records within a zone for the
+// given provider.
+func (r DomainRecords) zoneExtensions(providerName string) (zoneExtensions, error) {
+	factory, err := getProvider(providerName)
+	if err != nil {
+		return zoneExtensions{}, err
+	}
+
+	extensions := zoneExtensions{factory: factory, domains: make(map[Domain]any)}
+	for domain, rec := range r {
+		ext, err := rec.Extensions.parse(providerName)
+		if err != nil {
+			return zoneExtensions{}, fmt.Errorf("failed to parse extensions for %q: %w", domain, err)
+		}
+		if ext != nil {
+			extensions.domains[domain] = ext
+		}
+	}
+	return extensions, nil
+}
+
+// extend attaches the extensions of the domain to the given records that were
+// converted from the records of the domain. Extensions only apply to the
+// records at the domain itself of the types that the provider supports.
+// Records that are derived from them at other names, such as DKIM, DMARC and
+// MTA-STS records, are left without extensions, as are the records of other
+// types.
+func (e zoneExtensions) extend(domain Domain, subdomain string, records []libdns.Record) []ExtendedRecord {
+	ext := e.domains[domain]
+
+	extended := make([]ExtendedRecord, len(records))
+	for i, record := range records {
+		extended[i] = ExtendedRecord{Record: record}
+		if ext != nil && record.Name == subdomain && e.factory.extendsType(record.Type) {
+			extended[i].Extension = ext
+		}
+	}
+	return extended
+}
+
+// withoutExtensions returns the records as extended records without
+// extensions. It is used for records that are derived from the records of a
+// domain at other names, such as glue, DNS-SD and PTR records.
+func withoutExtensions(records []libdns.Record) []ExtendedRecord {
+	extended := make([]ExtendedRecord, len(records))
+	for i, record := range records {
+		extended[i] = ExtendedRecord{Record: record}
+	}
+	return extended
+}
+
+// extendRecords splits the records into those without extensions and those
+// with extensions.
+func extendRecords(records []ExtendedRecord) ([]libdns.Record, []ExtendedRecord) {
+	var plain []libdns.Record
+	var extended []ExtendedRecord
+	for _, record := range records {
+		if record.Extension != nil {
+			extended = append(extended, record)
+		} else {
+			plain = append(plain, record.Record)
+		}
+	}
+	return plain, extended
+}
+
+// plainRecords returns the records without their extensions.
+func plainRecords(records []ExtendedRecord) []libdns.Record {
+	plain := make([]libdns.Record, len(records))
+	for i, record := range records {
+		plain[i] = record.Record
+	}
+	return plain
+}
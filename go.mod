@@ -8,6 +8,7 @@ toolchain go1.21.7
 replace github.com/libdns/cloudflare => github.com/diamondburned/libdns-cloudflare v0.1.4-0.20250304082825-4f76fad2b46b
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/hexops/autogold/v2 v2.2.1
 	github.com/invopop/yaml v0.3.1
 	github.com/libdns/cloudflare v0.1.0
@@ -18,7 +19,9 @@ require (
 	github.com/libdns/vercel v0.0.2
 	github.com/lmittmann/tint v1.0.4
 	github.com/mattn/go-isatty v0.0.19
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/spf13/pflag v1.0.5
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -48,6 +51,5 @@ require (
 	golang.org/x/mod v0.12.0 // indirect
 	golang.org/x/sys v0.17.0 // indirect
 	golang.org/x/tools v0.12.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	mvdan.cc/gofumpt v0.5.0 // indirect
 )
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -35,9 +36,18 @@ func (a *HostAddresses) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalJSON marshals a single host address as a string and multiple host
+// addresses as an array.
+func (as HostAddresses) MarshalJSON() ([]byte, error) {
+	if len(as) == 1 {
+		return json.Marshal(as[0])
+	}
+	return json.Marshal([]HostAddress(as))
+}
+
 // ResolveIPs resolves the address strings into [net.IPAddr]s.
-// This function is not cached. [net.DefaultResolver] is used to
-// resolve the hostnames.
+// This function is not cached. Hostnames are resolved using the [Resolver]
+// of the context, which defaults to [net.DefaultResolver].
 func (as HostAddresses) ResolveIPs(ctx context.Context) ([]net.IPAddr, error) {
 	addrs := make([]net.IPAddr, 0, len(as))
 	for _, a := range as {
@@ -51,7 +61,8 @@ func (as HostAddresses) ResolveIPs(ctx context.Context) ([]net.IPAddr, error) {
 }
 
 // HostAddress is a string that has the host address plus optional flags
-// in the format `[flag1[,flag2[...]]!]<address>`.
+// in the format `[flag1[,flag2[...]]!]<address>`. An address of `@name`
+// references the named host from [Profile.Hosts] and cannot have flags.
 type HostAddress struct {
 	Address string           `json:"address"`
 	Flags   HostAddressFlags `json:"flags,omitempty"`
@@ -95,16 +106,17 @@ func (a *HostAddress) UnmarshalJSON(data []byte) error {
 		return a.UnmarshalText([]byte(str))
 
 	case '{':
-		var raw HostAddress
+		type alias HostAddress
+		var raw alias
 		if err := json.Unmarshal(data, &raw); err != nil {
 			return fmt.Errorf("failed to parse HostAddress object: %w", err)
 		}
 
-		if err := raw.Validate(); err != nil {
-			return fmt.Errorf("invalid HostAddress %q: %w", raw.String(), err)
+		if err := HostAddress(raw).Validate(); err != nil {
+			return fmt.Errorf("invalid HostAddress %q: %w", HostAddress(raw).String(), err)
 		}
 
-		*a = raw
+		*a = HostAddress(raw)
 		return nil
 
 	default:
@@ -133,18 +145,47 @@ func (a *HostAddress) UnmarshalText(data []byte) error {
 	return nil
 }
 
+// MarshalText marshals the host address in the format of
+// `[flag1[,flag2[...]]!]<address>`.
+func (a HostAddress) MarshalText() ([]byte, error) {
+	if len(a.Flags) == 0 {
+		return []byte(a.Address), nil
+	}
+	return []byte(a.Flags.String() + "!" + a.Address), nil
+}
+
 func (a HostAddress) Validate() error {
 	if err := a.Flags.Validate(); err != nil {
 		return fmt.Errorf("invalid host address flags: %w", err)
 	}
+	if name, ok := a.NamedHost(); ok {
+		if name == "" {
+			return errors.New("named host reference is missing a name")
+		}
+		if len(a.Flags) > 0 {
+			return errors.New("named host reference cannot have flags")
+		}
+	}
 	return nil
 }
 
+// NamedHost returns the name of the named host that the address references,
+// if any.
+func (a HostAddress) NamedHost() (string, bool) {
+	return strings.CutPrefix(a.Address, "@")
+}
+
 func (a HostAddress) String() string {
 	return fmt.Sprintf("%s!%s", a.Address, a.Flags.String())
 }
 
+// ResolveIPs resolves the address into [net.IPAddr]s. Named hosts are resolved
+// using the context returned by [WithNamedHosts].
 func (a HostAddress) ResolveIPs(ctx context.Context) ([]net.IPAddr, error) {
+	if name, ok := a.NamedHost(); ok {
+		return resolveNamedHost(ctx, name)
+	}
+
 	switch {
 	case a.Flags.Has(HostAddressIP):
 		return a.resolveAsIP(ctx)
@@ -264,7 +305,7 @@ func (a HostAddress) resolveAsExternal(ctx context.Context) ([]net.IPAddr, error
 }
 
 func (a HostAddress) resolveAsHostname(ctx context.Context) ([]net.IPAddr, error) {
-	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, a.Address)
+	addrs, err := resolverFrom(ctx).LookupIPAddr(ctx, a.Address)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve hostname %q: %w", a.Address, err)
 	}
@@ -0,0 +1,489 @@
+package dnsmill
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Position is a position within a profile file.
+type Position struct {
+	// File is the path to the profile file. It is empty if the profile was
+	// not loaded from a file.
+	File string
+	// Line is the line number, starting at 1. It is zero if the position is
+	// unknown.
+	Line int
+	// Column is the column number, starting at 1.
+	Column int
+}
+
+// String formats the position as `file:line:column`, leaving out the parts
+// that are unknown.
+func (p Position) String() string {
+	switch {
+	case p.Line == 0:
+		return p.File
+	case p.File == "":
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	default:
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+}
+
+// ProfileError is an error at a location within a profile. It is returned by
+// the functions that parse and validate profiles, possibly joined with other
+// errors using [errors.Join].
+type ProfileError struct {
+	// Path is the location of the erroneous value within the profile, such as
+	// `records["a.example.com"].hosts[2]`. Domains are always located under
+	// records, even if they are given at the top level of the profile.
+	Path string
+	// Pos is the position of the erroneous value within the profile file. It
+	// is only known for formats that preserve positions, such as YAML and
+	// JSON.
+	Pos Position
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ProfileError) Error() string {
+	msg := e.Err.Error()
+	if e.Path != "" {
+		msg = e.Path + ": " + msg
+	}
+	if pos := e.Pos.String(); pos != "" {
+		msg = pos + ": " + msg
+	}
+	return msg
+}
+
+func (e *ProfileError) Unwrap() error {
+	return e.Err
+}
+
+// recordsPath returns the path of the records of the given domain.
+func recordsPath(domain Domain) string {
+	return "records[" + strconv.Quote(string(domain)) + "]"
+}
+
+// providerPath returns the path of the given provider.
+func providerPath(name string) string {
+	return "providers[" + strconv.Quote(name) + "]"
+}
+
+// errorAt returns err as a [ProfileError] at the given path.
+func errorAt(path string, err error) error {
+	return &ProfileError{Path: path, Err: err}
+}
+
+// forEachProfileError calls fn for every [ProfileError] within err, including
+// those joined using [errors.Join]. It returns false if there are none.
+func forEachProfileError(err error, fn func(*ProfileError)) bool {
+	switch err := err.(type) {
+	case nil:
+		return false
+	case *ProfileError:
+		fn(err)
+		return true
+	case interface{ Unwrap() []error }:
+		var found bool
+		for _, err := range err.Unwrap() {
+			if forEachProfileError(err, fn) {
+				found = true
+			}
+		}
+		return found
+	default:
+		return forEachProfileError(errors.Unwrap(err), fn)
+	}
+}
+
+// setErrorPositions sets the positions of the profile errors within err that
+// do not have one yet using the given positions, which are keyed by path. It
+// returns false if there are no profile errors.
+func setErrorPositions(err error, positions map[string]Position) bool {
+	return forEachProfileError(err, func(perr *ProfileError) {
+		if perr.Pos.Line == 0 {
+			if pos, ok := positions[perr.Path]; ok {
+				perr.Pos = pos
+			}
+		}
+	})
+}
+
+// setErrorFile sets the file of the positions of the profile errors within
+// err. It returns false if there are no profile errors.
+func setErrorFile(err error, file string) bool {
+	return forEachProfileError(err, func(perr *ProfileError) {
+		if perr.Pos.File == "" {
+			perr.Pos.File = file
+		}
+	})
+}
+
+// sourceNode parses the profile data of the given format into YAML nodes.
+// Only YAML and JSON preserve the positions of the values. It returns false if
+// the format is unknown or the data cannot be parsed.
+func sourceNode(format string, data []byte) (*yaml.Node, bool) {
+	var root yaml.Node
+	switch format {
+	case "yaml", "json":
+		// JSON is a subset of YAML, so both can be parsed into YAML nodes.
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, false
+		}
+	case "toml":
+		var v map[string]any
+		if err := toml.Unmarshal(data, &v); err != nil {
+			return nil, false
+		}
+		if err := root.Encode(v); err != nil {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	return &root, true
+}
+
+// sourcePositions returns the positions of every value within the profile
+// data of the given format, keyed by path. The positions only contain the file
+// if the format does not preserve them.
+func sourcePositions(format string, data []byte, file string) map[string]Position {
+	root, ok := sourceNode(format, data)
+	if !ok {
+		return nil
+	}
+
+	positions := make(map[string]Position)
+	var walk func(n profileNode)
+	walk = func(n profileNode) {
+		if _, ok := positions[n.path]; !ok {
+			positions[n.path] = Position{File: file, Line: n.pos.Line, Column: n.pos.Column}
+		}
+		for _, child := range n.children() {
+			walk(child)
+		}
+	}
+	walk(rootProfileNode(root))
+	return positions
+}
+
+// locateYAMLError locates the value within the YAML (or JSON) data that
+// caused err when the data was unmarshaled into a profile.
+func locateYAMLError(data []byte, err error) error {
+	var root yaml.Node
+	if yerr := yaml.Unmarshal(data, &root); yerr != nil {
+		// Syntax errors of yaml.v3 already contain the line.
+		return yerr
+	}
+	if perr := rootProfileNode(&root).locate(); perr != nil {
+		return perr
+	}
+	return err
+}
+
+// locateValueError locates the value that caused err when the decoded value
+// was unmarshaled into a profile. Positions are not known, so only the path
+// is located.
+func locateValueError(v any, err error) error {
+	var root yaml.Node
+	if yerr := root.Encode(v); yerr != nil {
+		return err
+	}
+	if perr := rootProfileNode(&root).locate(); perr != nil {
+		perr.Pos = Position{}
+		return perr
+	}
+	return err
+}
+
+var (
+	profileType = reflect.TypeOf(Profile{})
+	recordsType = reflect.TypeOf(Records{})
+)
+
+// shorthandTypes maps types to the types that they are parsed as when given
+// in their shorthand form, which is anything but an object.
+var shorthandTypes = map[reflect.Type]reflect.Type{
+	recordsType:                      reflect.TypeOf(HostAddresses{}),
+	reflect.TypeOf(Delegation{}):     reflect.TypeOf([]Nameserver{}),
+	reflect.TypeOf(ProviderConfig{}): reflect.TypeOf(Domains{}),
+}
+
+// shorthandFields maps types to the field that is set by their shorthand
+// form.
+var shorthandFields = map[reflect.Type]string{
+	recordsType:                      "hosts",
+	reflect.TypeOf(Delegation{}):     "nameservers",
+	reflect.TypeOf(ProviderConfig{}): "zones",
+}
+
+// profileNode is a YAML node within a profile with the Go type that it is
+// unmarshaled into.
+type profileNode struct {
+	path string
+	// pos is the node used for the position, which is the key for values
+	// within objects.
+	pos  *yaml.Node
+	node *yaml.Node
+	typ  reflect.Type
+}
+
+func rootProfileNode(root *yaml.Node) profileNode {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	return profileNode{pos: node, node: node, typ: profileType}
+}
+
+// locate returns the error of the innermost node that fails to unmarshal, or
+// nil if the node unmarshals successfully.
+func (n profileNode) locate() *ProfileError {
+	err := n.unmarshal()
+	if err == nil {
+		return nil
+	}
+	for _, child := range n.children() {
+		if perr := child.locate(); perr != nil {
+			return perr
+		}
+	}
+	return &ProfileError{
+		Path: n.path,
+		Pos:  Position{Line: n.pos.Line, Column: n.pos.Column},
+		Err:  err,
+	}
+}
+
+// unmarshal unmarshals the node into its type through JSON, which is how the
+// profile itself is unmarshaled.
+func (n profileNode) unmarshal() error {
+	var v any
+	if err := n.node.Decode(&v); err != nil {
+		return err
+	}
+	b, err := json.Marshal(jsonCompatible(v))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, reflect.New(n.typ).Interface())
+}
+
+// children returns the child nodes of the node that can be typed.
+func (n profileNode) children() []profileNode {
+	node := n.node
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	typ := n.typ
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	if node.Kind != yaml.MappingNode {
+		if shorthand, ok := shorthandTypes[typ]; ok {
+			return []profileNode{{path: n.path, pos: n.pos, node: node, typ: shorthand}}
+		}
+	}
+
+	switch {
+	case node.Kind == yaml.MappingNode && (typ.Kind() == reflect.Struct || typ.Kind() == reflect.Map):
+		var children []profileNode
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			child := profileNode{pos: key, node: value}
+
+			switch {
+			case typ.Kind() == reflect.Map:
+				child.path = n.path + "[" + strconv.Quote(key.Value) + "]"
+				child.typ = typ.Elem()
+			case typ == profileType && key.Value != "records":
+				field, ok := jsonField(typ, key.Value)
+				if !ok {
+					// Every unknown field of the profile is a domain.
+					child.path = recordsPath(Domain(key.Value))
+					child.typ = recordsType
+					break
+				}
+				child.path = key.Value
+				child.typ = field
+			default:
+				field, ok := jsonField(typ, key.Value)
+				if !ok {
+					continue
+				}
+				child.path = joinPath(n.path, key.Value)
+				child.typ = field
+			}
+
+			children = append(children, child)
+		}
+		return children
+
+	case node.Kind == yaml.SequenceNode && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array):
+		children := make([]profileNode, len(node.Content))
+		for i, item := range node.Content {
+			children[i] = profileNode{
+				path: n.path + "[" + strconv.Itoa(i) + "]",
+				pos:  item,
+				node: item,
+				typ:  typ.Elem(),
+			}
+		}
+		return children
+
+	case node.Kind != yaml.SequenceNode && typ.Kind() == reflect.Slice:
+		// A single item is a shorthand for a list of one item.
+		return []profileNode{{path: n.path, pos: n.pos, node: node, typ: typ.Elem()}}
+
+	default:
+		return nil
+	}
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// jsonField returns the type of the struct field with the given JSON name,
+// including the fields of embedded structs.
+func jsonField(typ reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if t, ok := jsonField(field.Type, name); ok {
+				return t, true
+			}
+			continue
+		}
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == name {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
+
+// jsonCompatible converts the maps decoded by yaml.v3 with non-string keys
+// into maps with string keys, which is what invopop/yaml does.
+func jsonCompatible(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = jsonCompatible(e)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonCompatible(e)
+		}
+		return m
+	case []any:
+		for i, e := range v {
+			v[i] = jsonCompatible(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// offsetPosition returns the position of the byte offset within the data.
+func offsetPosition(data []byte, offset int64) Position {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte{'\n'}) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return Position{Line: line, Column: column}
+}
+
+// copyYAMLComments copies the comments of the values within the source
+// profile nodes to the values at the same paths within the destination
+// profile nodes. Values given in their shorthand form are matched with the
+// field that the shorthand sets.
+func copyYAMLComments(src, dst *yaml.Node) {
+	if src.Kind == yaml.DocumentNode && dst.Kind == yaml.DocumentNode {
+		copyNodeComments(src, dst)
+	}
+
+	sources := make(map[string]profileNode)
+	walkCommentPaths(rootProfileNode(src), "", func(path string, n profileNode) {
+		if _, ok := sources[path]; !ok {
+			sources[path] = n
+		}
+	})
+
+	copied := make(map[string]bool)
+	walkCommentPaths(rootProfileNode(dst), "", func(path string, n profileNode) {
+		source, ok := sources[path]
+		if !ok || copied[path] {
+			return
+		}
+		copied[path] = true
+		if source.pos != source.node && n.pos != n.node {
+			copyNodeComments(source.pos, n.pos)
+		}
+		copyNodeComments(source.node, n.node)
+	})
+
+	// The comment above the first field usually describes the whole
+	// profile, so it is kept at the top even if the field moves.
+	srcRoot, dstRoot := rootProfileNode(src).node, rootProfileNode(dst).node
+	if srcRoot.Kind == yaml.MappingNode && dstRoot.Kind == yaml.MappingNode &&
+		len(srcRoot.Content) > 0 && len(dstRoot.Content) > 0 {
+		srcFirst, dstFirst := srcRoot.Content[0], dstRoot.Content[0]
+		if srcFirst.HeadComment != "" && srcFirst.Value != dstFirst.Value {
+			for i := 0; i < len(dstRoot.Content); i += 2 {
+				if dstRoot.Content[i].Value == srcFirst.Value {
+					dstRoot.Content[i].HeadComment = ""
+				}
+			}
+			dstFirst.HeadComment = strings.TrimSpace(srcFirst.HeadComment + "\n" + dstFirst.HeadComment)
+		}
+	}
+}
+
+// walkCommentPaths calls fn for the node and its children with their paths,
+// where shorthand forms have the path of the field that they set.
+func walkCommentPaths(n profileNode, path string, fn func(path string, n profileNode)) {
+	fn(path, n)
+
+	typ := n.typ
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	for _, child := range n.children() {
+		childPath := path + strings.TrimPrefix(child.path, n.path)
+		if child.path == n.path && child.typ == shorthandTypes[typ] {
+			childPath = joinPath(path, shorthandFields[typ])
+		}
+		walkCommentPaths(child, childPath, fn)
+	}
+}
+
+func copyNodeComments(src, dst *yaml.Node) {
+	dst.HeadComment = src.HeadComment
+	dst.LineComment = src.LineComment
+	dst.FootComment = src.FootComment
+}
@@ -0,0 +1,374 @@
+package dnsmill
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// DMARC describes a DMARC policy record as described in RFC 7489. It is
+// placed at `_dmarc.<domain>`.
+type DMARC struct {
+	// Policy is the policy for the domain. It is required.
+	Policy DMARCPolicy `json:"p"`
+	// SubdomainPolicy is the policy for subdomains of the domain. If empty,
+	// Policy is used.
+	SubdomainPolicy DMARCPolicy `json:"sp,omitempty"`
+	// Percent is the percentage of messages that the policy is applied to.
+	// If nil, it defaults to 100.
+	Percent *int `json:"pct,omitempty"`
+	// AggregateReports lists the URIs that aggregate reports are sent to.
+	// Email addresses without a "mailto:" scheme are also accepted.
+	AggregateReports []string `json:"rua,omitempty"`
+	// FailureReports lists the URIs that failure reports are sent to.
+	// Email addresses without a "mailto:" scheme are also accepted.
+	FailureReports []string `json:"ruf,omitempty"`
+	// DKIMAlignment is the DKIM identifier alignment mode.
+	DKIMAlignment DMARCAlignment `json:"adkim,omitempty"`
+	// SPFAlignment is the SPF identifier alignment mode.
+	SPFAlignment DMARCAlignment `json:"aspf,omitempty"`
+}
+
+func (d *DMARC) UnmarshalJSON(data []byte) error {
+	type alias DMARC
+	var dmarc alias
+	if err := json.Unmarshal(data, &dmarc); err != nil {
+		return fmt.Errorf("failed to parse DMARC: %w", err)
+	}
+
+	if dmarc.Policy == "" {
+		return errors.New("DMARC must have a policy (p)")
+	}
+	if dmarc.Percent != nil && (*dmarc.Percent < 0 || *dmarc.Percent > 100) {
+		return fmt.Errorf("invalid DMARC pct %d: must be between 0 and 100", *dmarc.Percent)
+	}
+
+	*d = DMARC(dmarc)
+	return nil
+}
+
+// Validate validates the reporting URIs of the DMARC record. It is called
+// once the profile is expanded, since the URIs may be interpolated.
+func (d DMARC) Validate() error {
+	for _, uri := range d.AggregateReports {
+		if _, err := normalizeReportURI(uri, "mailto"); err != nil {
+			return fmt.Errorf("invalid DMARC rua: %w", err)
+		}
+	}
+	for _, uri := range d.FailureReports {
+		if _, err := normalizeReportURI(uri, "mailto"); err != nil {
+			return fmt.Errorf("invalid DMARC ruf: %w", err)
+		}
+	}
+	return nil
+}
+
+// TXT returns the DMARC TXT record value, such as
+// `v=DMARC1; p=reject; rua=mailto:dmarc@example.com`.
+func (d DMARC) TXT() string {
+	tags := []string{"v=DMARC1", "p=" + string(d.Policy)}
+	if d.SubdomainPolicy != "" {
+		tags = append(tags, "sp="+string(d.SubdomainPolicy))
+	}
+	if d.Percent != nil {
+		tags = append(tags, "pct="+strconv.Itoa(*d.Percent))
+	}
+	if len(d.AggregateReports) > 0 {
+		tags = append(tags, "rua="+joinReportURIs(d.AggregateReports, "mailto"))
+	}
+	if len(d.FailureReports) > 0 {
+		tags = append(tags, "ruf="+joinReportURIs(d.FailureReports, "mailto"))
+	}
+	if d.DKIMAlignment != "" {
+		tags = append(tags, "adkim="+string(d.DKIMAlignment))
+	}
+	if d.SPFAlignment != "" {
+		tags = append(tags, "aspf="+string(d.SPFAlignment))
+	}
+	return strings.Join(tags, "; ")
+}
+
+// Convert converts the DMARC policy into a TXT [libdns.Record] at
+// `_dmarc.<subdomain>`.
+func (d *DMARC) Convert(subdomain string, ttl TTL) []libdns.Record {
+	return []libdns.Record{
+		{
+			Type:  "TXT",
+			Name:  prefixSubdomain("_dmarc", subdomain),
+			Value: d.TXT(),
+			TTL:   ttl.Duration(),
+		},
+	}
+}
+
+// DMARCPolicy is the policy of a [DMARC] record.
+type DMARCPolicy string
+
+const (
+	DMARCNone       DMARCPolicy = "none"
+	DMARCQuarantine DMARCPolicy = "quarantine"
+	DMARCReject     DMARCPolicy = "reject"
+)
+
+func (p *DMARCPolicy) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("failed to parse DMARCPolicy: %w", err)
+	}
+	switch policy := DMARCPolicy(strings.ToLower(str)); policy {
+	case DMARCNone, DMARCQuarantine, DMARCReject:
+		*p = policy
+		return nil
+	default:
+		return fmt.Errorf("invalid DMARCPolicy %q", str)
+	}
+}
+
+// DMARCAlignment is the identifier alignment mode of a [DMARC] record.
+//
+// When parsing, it may either parse the mode character or its name, such as
+// "s" or "strict".
+type DMARCAlignment string
+
+const (
+	DMARCRelaxed DMARCAlignment = "r"
+	DMARCStrict  DMARCAlignment = "s"
+)
+
+func (a *DMARCAlignment) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("failed to parse DMARCAlignment: %w", err)
+	}
+	switch strings.ToLower(str) {
+	case "r", "relaxed":
+		*a = DMARCRelaxed
+	case "s", "strict":
+		*a = DMARCStrict
+	default:
+		return fmt.Errorf("invalid DMARCAlignment %q", str)
+	}
+	return nil
+}
+
+// MTASTS describes the MTA-STS policy of a domain as described in RFC 8461.
+// Its TXT record is placed at `_mta-sts.<domain>` with an id that is derived
+// from the policy, so the id changes whenever the policy changes.
+//
+// The policy itself must still be served at
+// `https://mta-sts.<domain>/.well-known/mta-sts.txt`. Either the path to the
+// served policy file is given, or the policy is declared here and can be
+// rendered using [MTASTS.Policy].
+type MTASTS struct {
+	// File is the path to the served policy file. It is mutually exclusive
+	// with the other fields.
+	File string `json:"file,omitempty"`
+	// Mode is the policy mode.
+	Mode MTASTSMode `json:"mode,omitempty"`
+	// MX lists the MX host patterns that the policy applies to.
+	MX []string `json:"mx,omitempty"`
+	// MaxAge is the maximum lifetime of the policy. It is required if the
+	// policy is declared here and must be at most a year.
+	MaxAge TTL `json:"maxAge,omitempty"`
+}
+
+// mtaSTSMaxAgeLimit is the maximum max_age of an MTA-STS policy.
+const mtaSTSMaxAgeLimit = 31557600 // seconds
+
+func (m *MTASTS) UnmarshalJSON(data []byte) error {
+	type alias MTASTS
+	var mtaSTS alias
+	if err := json.Unmarshal(data, &mtaSTS); err != nil {
+		return fmt.Errorf("failed to parse MTA-STS: %w", err)
+	}
+
+	if mtaSTS.File != "" {
+		if mtaSTS.Mode != "" || mtaSTS.MX != nil || mtaSTS.MaxAge != 0 {
+			return errors.New("MTA-STS file cannot be combined with mode, mx or maxAge")
+		}
+	} else {
+		if mtaSTS.Mode == "" {
+			return errors.New("MTA-STS must have either a file or a mode")
+		}
+		if mtaSTS.Mode != MTASTSNone && len(mtaSTS.MX) == 0 {
+			return fmt.Errorf("MTA-STS mode %q requires at least one mx", mtaSTS.Mode)
+		}
+		if mtaSTS.MaxAge == 0 {
+			return errors.New("MTA-STS must have a maxAge")
+		}
+		if mtaSTS.MaxAge.Duration().Seconds() > mtaSTSMaxAgeLimit {
+			return fmt.Errorf("MTA-STS maxAge must be at most %d seconds", mtaSTSMaxAgeLimit)
+		}
+	}
+
+	*m = MTASTS(mtaSTS)
+	return nil
+}
+
+// Policy returns the policy file contents, reading the file if needed.
+func (m MTASTS) Policy() (string, error) {
+	if m.File != "" {
+		b, err := os.ReadFile(m.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read MTA-STS policy file: %w", err)
+		}
+		return string(b), nil
+	}
+
+	var b strings.Builder
+	b.WriteString("version: STSv1\r\n")
+	fmt.Fprintf(&b, "mode: %s\r\n", m.Mode)
+	for _, mx := range m.MX {
+		fmt.Fprintf(&b, "mx: %s\r\n", mx)
+	}
+	fmt.Fprintf(&b, "max_age: %d\r\n", int64(m.MaxAge.Duration().Seconds()))
+	return b.String(), nil
+}
+
+// TXT returns the MTA-STS TXT record value, such as
+// `v=STSv1; id=5f3a2b1c0d9e8f7a`. The id is derived from the policy.
+func (m MTASTS) TXT() (string, error) {
+	policy, err := m.Policy()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(policy))
+	return "v=STSv1; id=" + hex.EncodeToString(sum[:8]), nil
+}
+
+// Convert converts the MTA-STS policy into a TXT [libdns.Record] at
+// `_mta-sts.<subdomain>`.
+func (m *MTASTS) Convert(subdomain string, ttl TTL) ([]libdns.Record, error) {
+	value, err := m.TXT()
+	if err != nil {
+		return nil, err
+	}
+	return []libdns.Record{
+		{
+			Type:  "TXT",
+			Name:  prefixSubdomain("_mta-sts", subdomain),
+			Value: value,
+			TTL:   ttl.Duration(),
+		},
+	}, nil
+}
+
+// MTASTSMode is the mode of an [MTASTS] policy.
+type MTASTSMode string
+
+const (
+	MTASTSEnforce MTASTSMode = "enforce"
+	MTASTSTesting MTASTSMode = "testing"
+	MTASTSNone    MTASTSMode = "none"
+)
+
+func (m *MTASTSMode) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("failed to parse MTASTSMode: %w", err)
+	}
+	switch mode := MTASTSMode(strings.ToLower(str)); mode {
+	case MTASTSEnforce, MTASTSTesting, MTASTSNone:
+		*m = mode
+		return nil
+	default:
+		return fmt.Errorf("invalid MTASTSMode %q", str)
+	}
+}
+
+// TLSRPT describes an SMTP TLS reporting record as described in RFC 8460.
+// It is placed at `_smtp._tls.<domain>`.
+type TLSRPT struct {
+	// Reports lists the URIs that reports are sent to. Only "mailto:" and
+	// "https:" URIs are allowed. Email addresses without a "mailto:" scheme
+	// are also accepted.
+	Reports []string `json:"rua"`
+}
+
+func (t *TLSRPT) UnmarshalJSON(data []byte) error {
+	type alias TLSRPT
+	var tlsRPT alias
+	if err := json.Unmarshal(data, &tlsRPT); err != nil {
+		return fmt.Errorf("failed to parse TLS-RPT: %w", err)
+	}
+
+	if len(tlsRPT.Reports) == 0 {
+		return errors.New("TLS-RPT must have at least one rua")
+	}
+
+	*t = TLSRPT(tlsRPT)
+	return nil
+}
+
+// Validate validates the reporting URIs of the TLS-RPT record. It is called
+// once the profile is expanded, since the URIs may be interpolated.
+func (t TLSRPT) Validate() error {
+	for _, uri := range t.Reports {
+		if _, err := normalizeReportURI(uri, "mailto", "https"); err != nil {
+			return fmt.Errorf("invalid TLS-RPT rua: %w", err)
+		}
+	}
+	return nil
+}
+
+// TXT returns the TLS-RPT TXT record value, such as
+// `v=TLSRPTv1; rua=mailto:tlsrpt@example.com`.
+func (t TLSRPT) TXT() string {
+	return "v=TLSRPTv1; rua=" + joinReportURIs(t.Reports, "mailto", "https")
+}
+
+// Convert converts the TLS-RPT policy into a TXT [libdns.Record] at
+// `_smtp._tls.<subdomain>`.
+func (t *TLSRPT) Convert(subdomain string, ttl TTL) []libdns.Record {
+	return []libdns.Record{
+		{
+			Type:  "TXT",
+			Name:  prefixSubdomain("_smtp._tls", subdomain),
+			Value: t.TXT(),
+			TTL:   ttl.Duration(),
+		},
+	}
+}
+
+// joinReportURIs normalizes the reporting URIs and joins them with commas.
+// Invalid URIs are kept as is, since they are reported by Validate.
+func joinReportURIs(uris []string, schemes ...string) string {
+	normalized := make([]string, len(uris))
+	for i, uri := range uris {
+		if n, err := normalizeReportURI(uri, schemes...); err == nil {
+			uri = n
+		}
+		normalized[i] = uri
+	}
+	return strings.Join(normalized, ",")
+}
+
+// normalizeReportURI validates a reporting URI against the allowed schemes.
+// Email addresses without a scheme are turned into "mailto:" URIs.
+func normalizeReportURI(uri string, schemes ...string) (string, error) {
+	scheme, rest, ok := strings.Cut(uri, ":")
+	if !ok {
+		if strings.Contains(uri, "@") {
+			return "mailto:" + uri, nil
+		}
+		return "", fmt.Errorf("%q is neither a URI nor an email address", uri)
+	}
+
+	for _, allowed := range schemes {
+		if strings.EqualFold(scheme, allowed) {
+			if rest == "" || strings.ContainsAny(rest, " ,;") {
+				return "", fmt.Errorf("invalid URI %q", uri)
+			}
+			return uri, nil
+		}
+	}
+
+	return "", fmt.Errorf("URI %q must use one of the schemes %q", uri, schemes)
+}
@@ -0,0 +1,140 @@
+package dnsmill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// NamedHosts maps names to host addresses. Records can reference a named host
+// as `@name` wherever a [HostAddress] is accepted, so the same machine's
+// addresses only have to be declared once.
+type NamedHosts map[string]HostAddresses
+
+// Validate validates the named hosts. Named hosts cannot reference other
+// named hosts.
+func (h NamedHosts) Validate() error {
+	var errs []error
+	for _, name := range sortedKeys(h) {
+		if name == "" || strings.ContainsAny(name, "@! \t") {
+			errs = append(errs, fmt.Errorf("invalid host name %q", name))
+		}
+		for _, addr := range h[name] {
+			if ref, ok := addr.NamedHost(); ok {
+				errs = append(errs, fmt.Errorf("host %q cannot reference another named host %q", name, ref))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type namedHostsKey struct{}
+
+// WithNamedHosts returns a context that resolves references to the given
+// named hosts. Each named host is resolved at most once within the returned
+// context, and the result is reused by every record that references it.
+// [Profile.Apply] calls this with [Profile.Hosts].
+func WithNamedHosts(ctx context.Context, hosts NamedHosts) context.Context {
+	return context.WithValue(ctx, namedHostsKey{}, &namedHostResolver{
+		hosts:    hosts,
+		resolved: make(map[string]namedHostResult, len(hosts)),
+	})
+}
+
+type namedHostResolver struct {
+	hosts    NamedHosts
+	mu       sync.Mutex
+	resolved map[string]namedHostResult
+}
+
+type namedHostResult struct {
+	addrs []net.IPAddr
+	err   error
+}
+
+func resolveNamedHost(ctx context.Context, name string) ([]net.IPAddr, error) {
+	r, _ := ctx.Value(namedHostsKey{}).(*namedHostResolver)
+	if r == nil {
+		return nil, fmt.Errorf("named host %q cannot be resolved without a profile", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result, ok := r.resolved[name]
+	if !ok {
+		hosts, ok := r.hosts[name]
+		if !ok {
+			return nil, fmt.Errorf("undefined host %q", name)
+		}
+		result.addrs, result.err = hosts.ResolveIPs(ctx)
+		r.resolved[name] = result
+	}
+
+	// Callers may filter the addresses in place, so never share the cached
+	// slice.
+	return slices.Clone(result.addrs), result.err
+}
+
+var hostAddressType = reflect.TypeOf(HostAddress{})
+
+// hostAddresses returns every host address within the records, including
+// those of delegations, SPF and service bindings.
+func (r *Records) hostAddresses() []HostAddress {
+	var addrs []HostAddress
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		if v.Type() == hostAddressType {
+			addrs = append(addrs, v.Interface().(HostAddress))
+			return
+		}
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				if v.Type().Field(i).IsExported() {
+					walk(v.Field(i))
+				}
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		case reflect.Map:
+			iter := v.MapRange()
+			for iter.Next() {
+				walk(iter.Value())
+			}
+		}
+	}
+	walk(reflect.ValueOf(r).Elem())
+	return addrs
+}
+
+// validateHostReferences checks that every named host referenced by the
+// records of the profile is defined.
+func validateHostReferences(p *Profile) []error {
+	var errs []error
+	for _, domain := range p.Records.sortedDomains() {
+		records := p.Records[domain]
+		for _, addr := range records.hostAddresses() {
+			name, ok := addr.NamedHost()
+			if !ok {
+				continue
+			}
+			if _, ok := p.Hosts[name]; !ok {
+				errs = append(errs, errorAt(recordsPath(domain), fmt.Errorf(
+					"%q references undefined host %q", domain, name)))
+			}
+		}
+	}
+	return errs
+}
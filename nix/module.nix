@@ -37,6 +37,35 @@ let
         '';
       };
 
+      vars = mkOption {
+        type = types.attrsOf types.str;
+        default = { };
+        example = {
+          mailhost = "mail.libdb.so";
+          publicIP = "\${env:PUBLIC_IP}";
+        };
+        description = ''
+          Variables that can be used within the string values of the profile
+          as ''${var:name}. Environment variables and files can also be used
+          as ''${env:NAME} and ''${file:path}.
+        '';
+      };
+
+      hosts = mkOption {
+        type = types.attrsOf (types.either types.str (types.listOf types.str));
+        default = { };
+        example = {
+          web1 = [
+            "interface!eth0"
+            "external,ipv4!"
+          ];
+        };
+        description = ''
+          Named hosts that records can reference as `@name` wherever a host
+          address is accepted.
+        '';
+      };
+
       config = mkOption {
         type = profileConfigType;
         default = { };
@@ -54,6 +83,8 @@ let
               Zones represents a list of zones that the provider manages.
             '';
           };
+
+          ttl = ttlOption;
         };
         example = {
           cloudflare.zones = [ "libdb.so" ];
@@ -87,9 +118,228 @@ let
             type = types.nullOr types.str;
             default = null;
             description = ''
-              CNAME represents a single CNAME record.
+              CNAME represents a single CNAME record. It cannot be used at the
+              apex of a zone; use alias instead.
+            '';
+          };
+
+          alias = mkOption {
+            type = types.nullOr types.str;
+            default = null;
+            description = ''
+              Alias represents an ALIAS record, which is resolved by dnsmill
+              into A and AAAA records when the profile is applied. Unlike
+              CNAME, it can be used at the apex of a zone.
+            '';
+          };
+
+          ns = mkOption {
+            type = types.nullOr (
+              types.oneOf [
+                # single nameserver
+                (types.str)
+                # multiple nameservers, either as hostnames or as
+                # { name, addresses } attrsets
+                (types.listOf (types.either types.str types.attrs))
+                # { nameservers, fromProvider } attrset
+                (types.attrs)
+              ]
+            );
+            default = null;
+            description = ''
+              NS represents NS records that delegate the subdomain to other
+              nameservers. Glue records are automatically created for
+              nameservers within the delegated subdomain.
+            '';
+          };
+
+          https = serviceBindingsOption "HTTPS";
+
+          svcb = serviceBindingsOption "SVCB";
+
+          tlsa = mkOption {
+            type = types.nullOr (types.either types.attrs (types.listOf types.attrs));
+            default = null;
+            description = ''
+              TLSA represents TLSA records for DANE. Each record has either
+              literal association data or a path to a PEM certificate or
+              public key file, from which the association data is computed.
+            '';
+          };
+
+          sshfp = mkOption {
+            type = types.nullOr (
+              types.oneOf [
+                # use the default host keys
+                (types.enum [ true ])
+                # single path or glob pattern
+                (types.str)
+                # multiple paths or glob patterns
+                (types.listOf types.str)
+              ]
+            );
+            default = null;
+            description = ''
+              SSHFP represents SSHFP records that are generated from OpenSSH
+              public key files. If true, the host keys at
+              /etc/ssh/ssh_host_*_key.pub are used.
+            '';
+          };
+
+          dkim = mkOption {
+            type = types.nullOr (
+              types.either (types.attrsOf types.str) (types.listOf (types.attrsOf types.str))
+            );
+            default = null;
+            example = {
+              selector = "2024";
+              file = "/var/lib/dkim/libdb.so.2024.key";
+            };
+            description = ''
+              DKIM represents DKIM TXT records that are derived from key files
+              and placed at <selector>._domainkey.<domain>.
+            '';
+          };
+
+          spf = mkOption {
+            type = types.nullOr types.attrs;
+            default = null;
+            example = {
+              mx = true;
+              include = [ "_spf.google.com" ];
+              all = "fail";
+            };
+            description = ''
+              SPF represents a structured SPF record with the a, mx, ip4, ip6,
+              include and all mechanisms. The ip4 and ip6 mechanisms take host
+              addresses.
             '';
           };
+
+          dmarc = mkOption {
+            type = types.nullOr types.attrs;
+            default = null;
+            example = {
+              p = "reject";
+              rua = [ "dmarc@libdb.so" ];
+            };
+            description = ''
+              DMARC represents a DMARC policy record placed at _dmarc.<domain>.
+            '';
+          };
+
+          mtaSts = mkOption {
+            type = types.nullOr types.attrs;
+            default = null;
+            example = {
+              mode = "enforce";
+              mx = [ "mail.libdb.so" ];
+              maxAge = "168h";
+            };
+            description = ''
+              MTASTS represents an MTA-STS policy record placed at
+              _mta-sts.<domain>. Its id is derived from the policy.
+            '';
+          };
+
+          tlsRpt = mkOption {
+            type = types.nullOr types.attrs;
+            default = null;
+            example = {
+              rua = [ "tlsrpt@libdb.so" ];
+            };
+            description = ''
+              TLSRPT represents an SMTP TLS reporting record placed at
+              _smtp._tls.<domain>.
+            '';
+          };
+
+          dnssd = mkOption {
+            type = types.nullOr (types.either types.attrs (types.listOf types.attrs));
+            default = null;
+            example = {
+              instance = "Office Printer";
+              service = "_ipp._tcp";
+              port = 631;
+              target = "printer.libdb.so.";
+              txt = {
+                rp = "printers/office";
+              };
+            };
+            description = ''
+              DNSSD represents DNS-SD service instances advertised within the
+              domain. Each instance expands into PTR, SRV and TXT records, and
+              its service type is enumerated under _services._dns-sd._udp.
+            '';
+          };
+
+          preset = mkOption {
+            type = types.nullOr (
+              types.oneOf [
+                types.str
+                types.attrs
+                (types.listOf (types.either types.str types.attrs))
+              ]
+            );
+            default = null;
+            example = [
+              "fastmail"
+              {
+                name = "github-pages";
+                params = {
+                  user = "diamondburned";
+                  verification = "0123456789abcdef";
+                };
+              }
+            ];
+            description = ''
+              Preset represents presets of records for common setups, such as
+              email hosting services. Each preset is either its name or an
+              attrset with a name and params.
+            '';
+          };
+
+          raw = mkOption {
+            type = types.nullOr (types.either types.attrs (types.listOf types.attrs));
+            default = null;
+            example = [
+              {
+                type = "LOC";
+                value = "52 22 23.000 N 4 53 32.000 E -2.00m 0.00m 10000m 10m";
+              }
+            ];
+            description = ''
+              Raw represents records of arbitrary types that are passed
+              through to the DNS provider unchanged. Each record has a type,
+              a value and optionally a ttl, priority, weight and target.
+            '';
+          };
+
+          extensions = mkOption {
+            type = types.nullOr (types.attrsOf types.attrs);
+            default = null;
+            example = {
+              cloudflare = {
+                proxied = true;
+                comment = "managed by dnsmill";
+              };
+            };
+            description = ''
+              Extensions contains provider-specific options of the records at
+              the domain, keyed by the name of the provider.
+            '';
+          };
+
+          ptr = mkOption {
+            type = types.bool;
+            default = false;
+            description = ''
+              Whether to create PTR records for the addresses of hosts in the
+              reverse zones that are managed in the same profile.
+            '';
+          };
+
+          ttl = ttlOption;
         };
         description = ''
           Records represents a list of DNS records. It is an attrset with
@@ -116,9 +366,42 @@ let
             - overwrite overwrites the existing DNS record with the new one.
         '';
       };
+
+      ttl = ttlOption;
+
+      allowUnknownFields = mkOption {
+        type = types.bool;
+        default = false;
+        description = ''
+          Whether to ignore unknown fields in the profile instead of rejecting
+          them, such as misspelled record types.
+        '';
+      };
     };
   };
 
+  ttlOption = mkOption {
+    type = types.nullOr (types.either types.ints.unsigned types.str);
+    default = null;
+    example = "5m";
+    description = ''
+      TTL is the time-to-live of the records, either as a number of seconds or
+      as a Go duration string. If null, the TTL is inherited from the provider
+      or profile configuration, falling back to the DNS provider's default.
+    '';
+  };
+
+  serviceBindingsOption =
+    type:
+    mkOption {
+      type = types.nullOr (types.either types.attrs (types.listOf types.attrs));
+      default = null;
+      description = ''
+        ${type} represents ${type} records as attrsets of priority, target and
+        service parameters.
+      '';
+    };
+
   attrsOfSubmodule =
     options:
     types.attrsOf (
@@ -0,0 +1,192 @@
+package dnsmill
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/libdns/libdns"
+)
+
+// Preset describes a reusable set of records for a common setup, such as the
+// records required by an email hosting service.
+type Preset struct {
+	// Name is the name of the preset.
+	Name string
+	// DocURL is the URL to the documentation that the records are based on.
+	DocURL string
+	// Params lists the parameters that the preset accepts.
+	Params []PresetParam
+	// Records returns the records of the preset for the given domain. The
+	// names of the records are relative to the domain, with "@" being the
+	// domain itself. The parameters are already validated against Params.
+	Records func(domain Domain, params map[string]string) ([]libdns.Record, error)
+}
+
+// PresetParam describes a parameter of a [Preset].
+type PresetParam struct {
+	// Name is the name of the parameter.
+	Name string
+	// Required is true if the parameter must be given.
+	Required bool
+	// Description describes the parameter.
+	Description string
+}
+
+// validateParams validates the given parameters against the preset's
+// parameters.
+func (p Preset) validateParams(params map[string]string) error {
+	for name := range params {
+		if !slices.ContainsFunc(p.Params, func(param PresetParam) bool { return param.Name == name }) {
+			return fmt.Errorf("unknown parameter %q for preset %q", name, p.Name)
+		}
+	}
+	for _, param := range p.Params {
+		if _, ok := params[param.Name]; param.Required && !ok {
+			return fmt.Errorf("preset %q requires parameter %q", p.Name, param.Name)
+		}
+	}
+	return nil
+}
+
+var presetRegistry = map[string]Preset{}
+
+// RegisterPreset registers a record preset into the global registry.
+func RegisterPreset(p Preset) {
+	if _, ok := presetRegistry[p.Name]; ok {
+		panic(fmt.Sprintf("preset %q already registered", p.Name))
+	}
+	presetRegistry[p.Name] = p
+}
+
+// ListPresets returns the list of registered record presets.
+func ListPresets() []Preset {
+	presets := make([]Preset, 0, len(presetRegistry))
+	for _, p := range presetRegistry {
+		presets = append(presets, p)
+	}
+	return presets
+}
+
+func getPreset(name string) (Preset, error) {
+	p, ok := presetRegistry[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("unknown preset: %q", name)
+	}
+	return p, nil
+}
+
+// PresetConfigs represents a list of presets used by a domain.
+//
+// When parsing, it may either parse a single preset or a list of presets.
+type PresetConfigs []PresetConfig
+
+func (c *PresetConfigs) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var items []PresetConfig
+	if bytes.HasPrefix(data, []byte{'['}) {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("failed to parse PresetConfigs array: %w", err)
+		}
+	} else {
+		var item PresetConfig
+		if err := json.Unmarshal(data, &item); err != nil {
+			return fmt.Errorf("failed to parse PresetConfigs object: %w", err)
+		}
+		items = []PresetConfig{item}
+	}
+
+	*c = items
+	return nil
+}
+
+// Validate validates that the presets are registered and that their
+// parameters are valid.
+func (c PresetConfigs) Validate() error {
+	for _, cfg := range c {
+		preset, err := getPreset(cfg.Name)
+		if err != nil {
+			return err
+		}
+		if err := preset.validateParams(cfg.Params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Convert expands the presets used by the given domain into a list of
+// [libdns.Record]s. The subdomain is the name of the domain relative to its
+// zone.
+func (c PresetConfigs) Convert(domain Domain, subdomain string, ttl TTL) ([]libdns.Record, error) {
+	var records []libdns.Record
+	for _, cfg := range c {
+		preset, err := getPreset(cfg.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := preset.validateParams(cfg.Params); err != nil {
+			return nil, err
+		}
+
+		recs, err := preset.Records(domain, cfg.Params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand preset %q: %w", cfg.Name, err)
+		}
+
+		for _, rec := range recs {
+			if rec.Name == "@" || rec.Name == "" {
+				rec.Name = subdomain
+			} else {
+				rec.Name = prefixSubdomain(rec.Name, subdomain)
+			}
+			if rec.Name == "" {
+				rec.Name = "@"
+			}
+			if rec.TTL == 0 {
+				rec.TTL = ttl.Duration()
+			}
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// PresetConfig describes a preset used by a domain.
+//
+// When parsing, it may either parse the name of the preset or the actual
+// [PresetConfig] instance itself.
+type PresetConfig struct {
+	// Name is the name of the preset.
+	Name string `json:"name"`
+	// Params contains the parameters of the preset.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+func (c *PresetConfig) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte{'"'}) {
+		var name string
+		if err := json.Unmarshal(data, &name); err != nil {
+			return fmt.Errorf("failed to parse PresetConfig name: %w", err)
+		}
+		*c = PresetConfig{Name: name}
+		return nil
+	}
+
+	type alias PresetConfig
+	var cfg alias
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to parse PresetConfig: %w", err)
+	}
+	if cfg.Name == "" {
+		return errors.New("preset must have a name")
+	}
+
+	*c = PresetConfig(cfg)
+	return nil
+}
@@ -0,0 +1,188 @@
+package dnsmill
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+func init() {
+	RegisterPreset(Preset{
+		Name:   "fastmail",
+		DocURL: "https://www.fastmail.help/hc/en-us/articles/360060591153-Manual-DNS-configuration",
+		Records: func(domain Domain, params map[string]string) ([]libdns.Record, error) {
+			records := []libdns.Record{
+				{Type: "MX", Name: "@", Value: "in1-smtp.messagingengine.com.", Priority: 10},
+				{Type: "MX", Name: "@", Value: "in2-smtp.messagingengine.com.", Priority: 20},
+				{Type: "TXT", Name: "@", Value: "v=spf1 include:spf.messagingengine.com ?all"},
+			}
+			for _, selector := range []string{"fm1", "fm2", "fm3"} {
+				records = append(records, libdns.Record{
+					Type:  "CNAME",
+					Name:  selector + "._domainkey",
+					Value: fmt.Sprintf("%s.%s.dkim.fmhosted.com.", selector, domain),
+				})
+			}
+			return records, nil
+		},
+	})
+
+	RegisterPreset(Preset{
+		Name:   "google-workspace",
+		DocURL: "https://support.google.com/a/answer/140034",
+		Params: []PresetParam{
+			{
+				Name:        "verification",
+				Description: "The google-site-verification TXT value used to verify the domain.",
+			},
+			{
+				Name:        "dkim",
+				Description: "The public key (the p= value) of the DKIM key generated in the Admin console.",
+			},
+		},
+		Records: func(domain Domain, params map[string]string) ([]libdns.Record, error) {
+			records := []libdns.Record{
+				{Type: "MX", Name: "@", Value: "smtp.google.com.", Priority: 1},
+				{Type: "TXT", Name: "@", Value: "v=spf1 include:_spf.google.com ~all"},
+			}
+			if v, ok := params["verification"]; ok {
+				records = append(records, libdns.Record{Type: "TXT", Name: "@", Value: v})
+			}
+			if key, ok := params["dkim"]; ok {
+				records = append(records, libdns.Record{
+					Type:  "TXT",
+					Name:  "google._domainkey",
+					Value: "v=DKIM1; k=rsa; p=" + key,
+				})
+			}
+			return records, nil
+		},
+	})
+
+	RegisterPreset(Preset{
+		Name:   "microsoft365",
+		DocURL: "https://learn.microsoft.com/en-us/microsoft-365/admin/get-help-with-domains/create-dns-records-at-any-dns-hosting-provider",
+		Params: []PresetParam{
+			{
+				Name:        "verification",
+				Description: "The MS=ms12345678 TXT value used to verify the domain.",
+			},
+			{
+				Name:        "tenant",
+				Description: "The name of the tenant's onmicrosoft.com domain, such as contoso. Enables the DKIM records.",
+			},
+		},
+		Records: func(domain Domain, params map[string]string) ([]libdns.Record, error) {
+			dashed := strings.ReplaceAll(string(domain), ".", "-")
+			records := []libdns.Record{
+				{Type: "MX", Name: "@", Value: dashed + ".mail.protection.outlook.com.", Priority: 0},
+				{Type: "TXT", Name: "@", Value: "v=spf1 include:spf.protection.outlook.com -all"},
+				{Type: "CNAME", Name: "autodiscover", Value: "autodiscover.outlook.com."},
+			}
+			if v, ok := params["verification"]; ok {
+				records = append(records, libdns.Record{Type: "TXT", Name: "@", Value: v})
+			}
+			if tenant, ok := params["tenant"]; ok {
+				for _, selector := range []string{"selector1", "selector2"} {
+					records = append(records, libdns.Record{
+						Type:  "CNAME",
+						Name:  selector + "._domainkey",
+						Value: fmt.Sprintf("%s-%s._domainkey.%s.onmicrosoft.com.", selector, dashed, tenant),
+					})
+				}
+			}
+			return records, nil
+		},
+	})
+
+	RegisterPreset(Preset{
+		Name:   "protonmail",
+		DocURL: "https://proton.me/support/custom-domain",
+		Params: []PresetParam{
+			{
+				Name:        "verification",
+				Required:    true,
+				Description: "The protonmail-verification TXT value used to verify the domain.",
+			},
+			{
+				Name:        "dkim",
+				Required:    true,
+				Description: "The domain-specific part of the DKIM CNAME targets, which is the part before .domains.proton.ch.",
+			},
+		},
+		Records: func(domain Domain, params map[string]string) ([]libdns.Record, error) {
+			records := []libdns.Record{
+				{Type: "TXT", Name: "@", Value: params["verification"]},
+				{Type: "MX", Name: "@", Value: "mail.protonmail.ch.", Priority: 10},
+				{Type: "MX", Name: "@", Value: "mailsec.protonmail.ch.", Priority: 20},
+				{Type: "TXT", Name: "@", Value: "v=spf1 include:_spf.protonmail.ch ~all"},
+			}
+			for _, selector := range []string{"protonmail", "protonmail2", "protonmail3"} {
+				records = append(records, libdns.Record{
+					Type:  "CNAME",
+					Name:  selector + "._domainkey",
+					Value: fmt.Sprintf("%s.domainkey.%s.domains.proton.ch.", selector, params["dkim"]),
+				})
+			}
+			return records, nil
+		},
+	})
+
+	RegisterPreset(Preset{
+		Name:   "github-pages",
+		DocURL: "https://docs.github.com/en/pages/configuring-a-custom-domain-for-your-github-pages-site/managing-a-custom-domain-for-your-github-pages-site",
+		Params: []PresetParam{
+			{
+				Name:        "user",
+				Description: "The GitHub user or organization that owns the site. Required for verification.",
+			},
+			{
+				Name:        "verification",
+				Description: "The verification code of the domain.",
+			},
+		},
+		Records: func(domain Domain, params map[string]string) ([]libdns.Record, error) {
+			var records []libdns.Record
+			for i := 0; i < 4; i++ {
+				records = append(records,
+					libdns.Record{Type: "A", Name: "@", Value: fmt.Sprintf("185.199.%d.153", 108+i)},
+					libdns.Record{Type: "AAAA", Name: "@", Value: fmt.Sprintf("2606:50c0:800%d::153", i)},
+				)
+			}
+			if code, ok := params["verification"]; ok {
+				user, ok := params["user"]
+				if !ok {
+					return nil, errors.New("verification requires the user parameter")
+				}
+				records = append(records, libdns.Record{
+					Type:  "TXT",
+					Name:  "_github-pages-challenge-" + user,
+					Value: code,
+				})
+			}
+			return records, nil
+		},
+	})
+
+	RegisterPreset(Preset{
+		Name:   "netlify",
+		DocURL: "https://docs.netlify.com/domains-https/custom-domains/configure-external-dns/",
+		Params: []PresetParam{
+			{
+				Name:        "verification",
+				Description: "The value of the netlify-challenge TXT record used to verify the domain.",
+			},
+		},
+		Records: func(domain Domain, params map[string]string) ([]libdns.Record, error) {
+			records := []libdns.Record{
+				{Type: "A", Name: "@", Value: "75.2.60.5"},
+			}
+			if v, ok := params["verification"]; ok {
+				records = append(records, libdns.Record{Type: "TXT", Name: "netlify-challenge", Value: v})
+			}
+			return records, nil
+		},
+	})
+}
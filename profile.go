@@ -1,14 +1,21 @@
 package dnsmill
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
+	"slices"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	"github.com/invopop/yaml"
+	"github.com/libdns/libdns"
+	yaml3 "gopkg.in/yaml.v3"
 )
 
 // Profile represents a DNS profile. It contains the configuration and DNS
@@ -18,6 +25,16 @@ import (
 // that is not "config" is considered a domain name and is filled into the
 // "domains" field.
 type Profile struct {
+	// Include lists other profile files to merge into this profile. Paths are
+	// relative to the including file and may be globs or directories.
+	// Includes are resolved by [LoadProfile].
+	Include Includes `json:"include,omitempty"`
+	// Vars contains variables that can be used within the string values of
+	// the profile as `${var:name}`. See [Profile.Expand].
+	Vars map[string]string `json:"vars,omitempty"`
+	// Hosts contains named hosts that records can reference as `@name`
+	// instead of repeating the same host addresses.
+	Hosts NamedHosts `json:"hosts,omitempty"`
 	// Config configures the behavior of the DNS tool.
 	Config Config `json:"config,omitempty"`
 	// Providers lists the DNS providers that are used in the profile.
@@ -44,36 +61,211 @@ func parseProfileAsYAML(r io.Reader) (*Profile, error) {
 
 	p := NewProfile()
 	if err := yaml.Unmarshal(b, p); err != nil {
-		return nil, err
+		// invopop/yaml converts the YAML to JSON before unmarshaling, which
+		// loses the location of the error, so find it again.
+		return nil, locateYAMLError(b, err)
 	}
 
 	return p, nil
 }
 
-// ParseProfileAsYAML parses the profile from the YAML data.
-func ParseProfileAsYAML(r io.Reader) (*Profile, error) {
-	p, err := parseProfileAsYAML(r)
+func marshalProfileAsYAML(p *Profile) ([]byte, error) {
+	node, err := profileYAMLNode(p)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		return nil, err
 	}
-	return p, p.Validate()
+	restyleYAMLNode(node)
+	return encodeProfileYAML(node)
+}
+
+func formatProfileAsYAML(p *Profile, source []byte) ([]byte, error) {
+	node, err := profileYAMLNode(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceNode yaml3.Node
+	if err := yaml3.Unmarshal(source, &sourceNode); err == nil {
+		copyYAMLComments(&sourceNode, node)
+	}
+
+	restyleYAMLNode(node)
+	return encodeProfileYAML(node)
+}
+
+// profileYAMLNode marshals the profile into YAML nodes.
+func profileYAMLNode(p *Profile) (*yaml3.Node, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the JSON as YAML nodes rather than values to keep the order of
+	// the fields.
+	var node yaml3.Node
+	if err := yaml3.Unmarshal(b, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// restyleYAMLNode restyles the YAML nodes parsed from JSON to use block style,
+// except for lists of scalars without comments, which use flow style.
+func restyleYAMLNode(node *yaml3.Node) {
+	node.Style = 0
+	if node.Kind == yaml3.SequenceNode && !slices.ContainsFunc(node.Content, func(n *yaml3.Node) bool {
+		return n.Kind != yaml3.ScalarNode || n.HeadComment != "" || n.LineComment != "" || n.FootComment != ""
+	}) {
+		node.Style = yaml3.FlowStyle
+	}
+	for _, child := range node.Content {
+		restyleYAMLNode(child)
+	}
+}
+
+// encodeProfileYAML encodes the YAML nodes of a profile, separating the
+// top-level fields with blank lines.
+func encodeProfileYAML(node *yaml3.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml3.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	out := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if i > 0 && isTopLevelYAMLKey(line) {
+			// Keep the comments of the field together with it.
+			start := len(out)
+			for start > 0 && strings.HasPrefix(out[start-1], "#") {
+				start--
+			}
+			if start > 0 && out[start-1] != "\n" {
+				out = slices.Insert(out, start, "\n")
+			}
+		}
+		out = append(out, line)
+	}
+	return []byte(strings.Join(out, "")), nil
+}
+
+func isTopLevelYAMLKey(line string) bool {
+	return line != "" && !strings.ContainsRune(" \t\n#-", rune(line[0]))
+}
+
+// ParseProfileAsYAML parses the profile from the YAML data.
+func ParseProfileAsYAML(r io.Reader) (*Profile, error) {
+	return ParseProfile(r, "yaml")
 }
 
 func parseProfileAsJSON(r io.Reader) (*Profile, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data: %w", err)
+	}
+
 	p := NewProfile()
-	if err := json.NewDecoder(r).Decode(p); err != nil {
-		return nil, err
+	if err := json.Unmarshal(b, p); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, &ProfileError{Pos: offsetPosition(b, syntaxErr.Offset), Err: err}
+		}
+		return nil, locateYAMLError(b, err)
 	}
+
 	return p, nil
 }
 
+func marshalProfileAsJSON(p *Profile) ([]byte, error) {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
 // ParseProfileAsJSON parses the profile from the JSON data.
 func ParseProfileAsJSON(r io.Reader) (*Profile, error) {
-	p, err := parseProfileAsJSON(r)
+	return ParseProfile(r, "json")
+}
+
+func parseProfileAsTOML(r io.Reader) (*Profile, error) {
+	// Like invopop/yaml, convert the TOML data to JSON so that the profile
+	// only has to implement JSON unmarshaling.
+	var v map[string]any
+	if _, err := toml.NewDecoder(r).Decode(&v); err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert TOML to JSON: %w", err)
+	}
+
+	p := NewProfile()
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, locateValueError(v, err)
+	}
+
+	return p, nil
+}
+
+func marshalProfileAsTOML(p *Profile) ([]byte, error) {
+	b, err := json.Marshal(p)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v map[string]any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+	if err := enc.Encode(tomlCompatible(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// tomlCompatible converts the JSON numbers within the value into integers
+// where possible, since TOML distinguishes between integers and floats.
+func tomlCompatible(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = tomlCompatible(e)
+		}
+		return v
+	case []any:
+		for i, e := range v {
+			v[i] = tomlCompatible(e)
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
 	}
-	return p, p.Validate()
+}
+
+// ParseProfileAsTOML parses the profile from the TOML data.
+func ParseProfileAsTOML(r io.Reader) (*Profile, error) {
+	return ParseProfile(r, "toml")
 }
 
 // UnmarshalJSON unmarshals the JSON data into the profile.
@@ -90,6 +282,27 @@ func (p *Profile) UnmarshalJSON(data []byte) error {
 		delete(raw, "config")
 	}
 
+	if _, ok := raw["include"]; ok {
+		if err := json.Unmarshal(raw["include"], &p.Include); err != nil {
+			return fmt.Errorf("failed to parse include JSON: %w", err)
+		}
+		delete(raw, "include")
+	}
+
+	if _, ok := raw["vars"]; ok {
+		if err := json.Unmarshal(raw["vars"], &p.Vars); err != nil {
+			return fmt.Errorf("failed to parse vars JSON: %w", err)
+		}
+		delete(raw, "vars")
+	}
+
+	if _, ok := raw["hosts"]; ok {
+		if err := json.Unmarshal(raw["hosts"], &p.Hosts); err != nil {
+			return fmt.Errorf("failed to parse hosts JSON: %w", err)
+		}
+		delete(raw, "hosts")
+	}
+
 	if _, ok := raw["providers"]; ok {
 		if err := json.Unmarshal(raw["providers"], &p.Providers); err != nil {
 			return fmt.Errorf("failed to parse providers JSON: %w", err)
@@ -121,14 +334,111 @@ func (p *Profile) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-// Validate validates the profile.
+// MarshalJSON marshals the profile into a JSON object with its fields in the
+// order that they are documented, leaving out the config if it is the
+// default. Domains are flattened into the object after the providers, sorted
+// by name, unless one of them has the name of a field, in which case they are
+// marshaled under the "records" field instead.
+func (p Profile) MarshalJSON() ([]byte, error) {
+	var fields []profileField
+	if len(p.Include) > 0 {
+		fields = append(fields, profileField{"include", p.Include})
+	}
+	if len(p.Vars) > 0 {
+		fields = append(fields, profileField{"vars", p.Vars})
+	}
+	if len(p.Hosts) > 0 {
+		fields = append(fields, profileField{"hosts", p.Hosts})
+	}
+	if p.Config != DefaultConfig() {
+		fields = append(fields, profileField{"config", p.Config})
+	}
+	if len(p.Providers) > 0 {
+		fields = append(fields, profileField{"providers", p.Providers})
+	}
+	if slices.ContainsFunc(p.Records.sortedDomains(), isProfileField) {
+		fields = append(fields, profileField{"records", p.Records})
+	} else {
+		for _, domain := range p.Records.sortedDomains() {
+			fields = append(fields, profileField{string(domain), p.Records[domain]})
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", field.name, err)
+		}
+		fmt.Fprintf(&buf, "%q:", field.name)
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type profileField struct {
+	name  string
+	value any
+}
+
+// isProfileField returns true if the domain has the name of a profile field,
+// which means that it cannot be flattened into the profile.
+func isProfileField(domain Domain) bool {
+	_, ok := jsonField(profileType, string(domain))
+	return ok
+}
+
+var errUnresolvedIncludes = errors.New("profile includes must be resolved by loading the profile using LoadProfile")
+
+// Validate validates the profile without making any network calls. Besides
+// checking that each domain belongs to a zone, it rejects CNAME records that
+// coexist with other records or are at a zone apex, duplicate records, records
+// that collide with nested zones, and references to undefined named hosts.
+// All problems are reported at once using [errors.Join].
+//
+// A CNAME record is also rejected if its target is declared in the profile
+// without any records, since profiles cannot delete records. Targets that are
+// not declared in the profile are not checked, and neither are records that
+// exist at the provider but not in the profile, since that would require
+// network calls.
 func (p *Profile) Validate() error {
-	_, err := mapRootDomains(p)
+	var errs []error
+
+	if len(p.Include) > 0 {
+		errs = append(errs, errorAt("include", errUnresolvedIncludes))
+	}
+
+	if err := p.Hosts.Validate(); err != nil {
+		errs = append(errs, errorAt("hosts", fmt.Errorf("invalid hosts: %w", err)))
+	}
+	errs = append(errs, validateHostReferences(p)...)
+
+	rootDomains, err := mapRootDomains(p)
 	if err != nil {
-		return err
+		errs = append(errs, err)
 	}
 
-	return nil
+	for _, domain := range p.Records.sortedDomains() {
+		records := p.Records[domain]
+		if err := records.Preset.Validate(); err != nil {
+			errs = append(errs, errorAt(recordsPath(domain)+".preset", fmt.Errorf(
+				"invalid preset for %q: %w", domain, err)))
+		}
+		if err := records.Extensions.Validate(records.ownTypes()); err != nil {
+			errs = append(errs, errorAt(recordsPath(domain)+".extensions", fmt.Errorf(
+				"invalid extensions for %q: %w", domain, err)))
+		}
+		errs = append(errs, records.validate(domain)...)
+	}
+
+	errs = append(errs, validateRecords(p, rootDomains)...)
+
+	return errors.Join(errs...)
 }
 
 // Apply applies the profile to the DNS providers.
@@ -140,7 +450,18 @@ func (p *Profile) Validate() error {
 // If an error occurs, it will finish applying the profile to other zones before
 // returning the error. This way, the profile is applied as much as possible
 // before failing. Multiple errors will be joined using [errors.Join].
+//
+// Each named host in [Profile.Hosts] is resolved at most once per call.
+// Profiles with unresolved includes are rejected before any provider is
+// created, since they would only apply part of the records.
 func (p *Profile) Apply(ctx context.Context, logger *slog.Logger, dryRun bool) error {
+	if len(p.Include) > 0 {
+		return errUnresolvedIncludes
+	}
+
+	ctx = WithNamedHosts(ctx, p.Hosts)
+
+	factories := make(map[string]ProviderFactory, len(p.Providers))
 	providers := make(map[string]Provider, len(p.Providers))
 	for name := range p.Providers {
 		factory, err := getProvider(name)
@@ -151,21 +472,68 @@ func (p *Profile) Apply(ctx context.Context, logger *slog.Logger, dryRun bool) e
 		if err != nil {
 			return fmt.Errorf("failed to create provider %q: %w", name, err)
 		}
+		factories[name] = factory
 		providers[name] = p
 	}
 
-	apply := func(root mappedRootDomain, logger *slog.Logger) error {
-		libdnsRecords, err := root.Subdomains.Convert(ctx, root.RootDomain)
+	var errs []error
+
+	rootDomains, err := mapRootDomains(p)
+	if err != nil {
+		return err
+	}
+
+	// Check that the providers support the extensions of their zones before
+	// applying anything, so that no zone is applied without its extensions.
+	extensions := make([]zoneExtensions, len(rootDomains))
+	for i, root := range rootDomains {
+		extensions[i], err = root.Subdomains.zoneExtensions(root.ProviderName)
+		if err != nil {
+			return err
+		}
+		if len(extensions[i].domains) == 0 {
+			continue
+		}
+		if _, ok := providers[root.ProviderName].(RecordExtender); !ok {
+			return fmt.Errorf("provider %q does not support extensions", root.ProviderName)
+		}
+	}
+
+	// Convert every zone before applying any, since the PTR records of reverse
+	// zones are built from the addresses that the forward records resolved to.
+	ptrAddrs := make(map[Domain][]net.IPAddr)
+	converted := make([][]ExtendedRecord, len(rootDomains))
+	convertErrs := make([]error, len(rootDomains))
+	for i, root := range rootDomains {
+		subdomains, err := root.resolveDelegations(ctx, rootDomains, providers)
 		if err != nil {
-			return fmt.Errorf("failed to convert records for %q: %w", root.RootDomain, err)
+			convertErrs[i] = fmt.Errorf("failed to resolve delegations for %q: %w", root.RootDomain, err)
+			continue
 		}
+		converted[i], convertErrs[i] = root.convert(ctx, subdomains, extensions[i], ptrAddrs)
+	}
 
-		for _, record := range libdnsRecords {
+	apply := func(root mappedRootDomain, records []ExtendedRecord, logger *slog.Logger) error {
+		factory := factories[root.ProviderName]
+		for i, record := range records {
+			if ttl := factory.clampTTL(record.TTL); ttl != record.TTL {
+				logger.Warn(
+					"record TTL is out of the provider's range, clamping",
+					"record.type", record.Type,
+					"record.name", record.Name,
+					"record.ttl", record.TTL,
+					"clamped_ttl", ttl)
+				records[i].TTL = ttl
+			}
+		}
+
+		for _, record := range records {
 			logger.Info(
 				"applying fresh libdns record",
 				"record.type", record.Type,
 				"record.name", record.Name,
-				"record.value", record.Value)
+				"record.value", record.Value,
+				"record.ttl", record.TTL)
 		}
 
 		if dryRun {
@@ -173,16 +541,9 @@ func (p *Profile) Apply(ctx context.Context, logger *slog.Logger, dryRun bool) e
 			return nil
 		}
 
-		provider := providers[root.ProviderName]
-		switch p.Config.DuplicatePolicy {
-		case ErrorOnDuplicate:
-			libdnsRecords, err = provider.AppendRecords(ctx, string(root.RootDomain), libdnsRecords)
-		case OverwriteDuplicate:
-			libdnsRecords, err = provider.SetRecords(ctx, string(root.RootDomain), libdnsRecords)
-		default:
-			panic("unknown duplicate policy")
-		}
+		plain, extended := extendRecords(records)
 
+		libdnsRecords, err := applyRecords(ctx, providers[root.ProviderName], string(root.RootDomain), p.Config.DuplicatePolicy, plain, extended)
 		if err != nil {
 			return fmt.Errorf("failed to apply records for %q: %w", root.RootDomain, err)
 		}
@@ -192,26 +553,24 @@ func (p *Profile) Apply(ctx context.Context, logger *slog.Logger, dryRun bool) e
 				"applied libdns record",
 				"record.type", record.Type,
 				"record.name", record.Name,
-				"record.value", record.Value)
+				"record.value", record.Value,
+				"record.ttl", record.TTL)
 		}
 
 		return nil
 	}
 
-	var errs []error
-
-	rootDomains, err := mapRootDomains(p)
-	if err != nil {
-		return err
-	}
-
 	// TODO: parallelize
-	for _, root := range rootDomains {
+	for i, root := range rootDomains {
 		logger := logger.With(
 			"provider", root.ProviderName,
 			"root_domain", root.RootDomain)
 
-		if err := apply(root, logger); err != nil {
+		err := convertErrs[i]
+		if err == nil {
+			err = apply(root, append(converted[i], root.convertPTRs(ptrAddrs)...), logger)
+		}
+		if err != nil {
 			logger.Error(
 				"cannot apply domain",
 				"err", err)
@@ -222,3 +581,111 @@ func (p *Profile) Apply(ctx context.Context, logger *slog.Logger, dryRun bool) e
 
 	return errors.Join(errs...)
 }
+
+// applyRecords applies the records to the provider using the duplicate
+// policy. Records with extensions are applied through the provider's
+// [RecordExtender], which must be implemented if there are any.
+func applyRecords(ctx context.Context, provider Provider, zone string, policy DuplicatePolicy, records []libdns.Record, extended []ExtendedRecord) ([]libdns.Record, error) {
+	extender, _ := provider.(RecordExtender)
+
+	var applied, appliedExtended []libdns.Record
+	var err error
+
+	switch policy {
+	case ErrorOnDuplicate:
+		if len(records) > 0 {
+			applied, err = provider.AppendRecords(ctx, zone, records)
+		}
+		if err == nil && len(extended) > 0 {
+			appliedExtended, err = extender.AppendExtendedRecords(ctx, zone, extended)
+		}
+	case OverwriteDuplicate:
+		if len(records) > 0 {
+			applied, err = provider.SetRecords(ctx, zone, records)
+		}
+		if err == nil && len(extended) > 0 {
+			appliedExtended, err = extender.SetExtendedRecords(ctx, zone, extended)
+		}
+	default:
+		panic("unknown duplicate policy")
+	}
+
+	return append(applied, appliedExtended...), err
+}
+
+// RenderZones converts the records of the profile into the zones that they
+// belong to, sorted by origin, exactly as [Profile.Apply] would apply them
+// but without changing any records. DNS-SD instance names are escaped for use
+// in a zone file. Host addresses are resolved as usual, and
+// providers are only created to list the nameservers of delegations using
+// [Delegation.FromProvider].
+func (p *Profile) RenderZones(ctx context.Context) ([]*Zone, error) {
+	ctx = WithNamedHosts(ctx, p.Hosts)
+
+	rootDomains, err := mapRootDomains(p)
+	if err != nil {
+		return nil, err
+	}
+
+	providers := make(map[string]Provider)
+	for _, root := range rootDomains {
+		for domain, records := range root.Subdomains {
+			if records.NS == nil || !records.NS.FromProvider {
+				continue
+			}
+			child := rootDomains[slices.IndexFunc(rootDomains, func(d mappedRootDomain) bool {
+				return d.RootDomain == domain
+			})]
+			if _, ok := providers[child.ProviderName]; ok {
+				continue
+			}
+			factory, err := getProvider(child.ProviderName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get provider %q: %w", child.ProviderName, err)
+			}
+			provider, err := factory.New(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create provider %q: %w", child.ProviderName, err)
+			}
+			providers[child.ProviderName] = provider
+		}
+	}
+
+	// Convert every zone before building the PTR records of reverse zones,
+	// which are built from the addresses that the forward records resolved to.
+	ptrAddrs := make(map[Domain][]net.IPAddr)
+	converted := make([][]ExtendedRecord, len(rootDomains))
+	for i, root := range rootDomains {
+		subdomains, err := root.resolveDelegations(ctx, rootDomains, providers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve delegations for %q: %w", root.RootDomain, err)
+		}
+
+		converted[i], err = root.convert(ctx, subdomains, zoneExtensions{}, ptrAddrs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	zones := make([]*Zone, 0, len(rootDomains))
+	for i, root := range rootDomains {
+		factory, err := getProvider(root.ProviderName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get provider %q: %w", root.ProviderName, err)
+		}
+
+		records := plainRecords(append(converted[i], root.convertPTRs(ptrAddrs)...))
+		root.escapeDNSSDInstances(records)
+		for j, record := range records {
+			records[j].TTL = factory.clampTTL(record.TTL)
+		}
+
+		zones = append(zones, &Zone{
+			Origin:  root.RootDomain,
+			TTL:     TTL(factory.clampTTL(root.TTL.Duration())),
+			Records: records,
+		})
+	}
+
+	return zones, nil
+}
@@ -0,0 +1,192 @@
+package dnsmill
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ProfileFormat describes a file format that profiles can be written in.
+type ProfileFormat struct {
+	// Name is the name of the format, such as "yaml".
+	Name string
+	// Extensions lists the file extensions of the format, including the
+	// leading dot, such as ".yaml" and ".yml".
+	Extensions []string
+	// Parse parses the profile from the data. The profile is validated by
+	// the caller, so Parse should not call [Profile.Validate] itself.
+	Parse func(r io.Reader) (*Profile, error)
+	// Marshal marshals the profile into the format. If nil, profiles cannot
+	// be written in the format.
+	Marshal func(p *Profile) ([]byte, error)
+	// Format marshals the profile parsed from the source data into the
+	// format like Marshal, while keeping what it can from the source, such as
+	// comments. If nil, Marshal is used.
+	Format func(p *Profile, source []byte) ([]byte, error)
+}
+
+func init() {
+	RegisterProfileFormat(ProfileFormat{
+		Name:       "yaml",
+		Extensions: []string{".yaml", ".yml"},
+		Parse:      parseProfileAsYAML,
+		Marshal:    marshalProfileAsYAML,
+		Format:     formatProfileAsYAML,
+	})
+	RegisterProfileFormat(ProfileFormat{
+		Name:       "json",
+		Extensions: []string{".json"},
+		Parse:      parseProfileAsJSON,
+		Marshal:    marshalProfileAsJSON,
+	})
+	RegisterProfileFormat(ProfileFormat{
+		Name:       "toml",
+		Extensions: []string{".toml"},
+		Parse:      parseProfileAsTOML,
+		Marshal:    marshalProfileAsTOML,
+	})
+}
+
+var profileFormatRegistry = map[string]ProfileFormat{}
+
+// RegisterProfileFormat registers a profile format into the global registry.
+func RegisterProfileFormat(f ProfileFormat) {
+	if _, ok := profileFormatRegistry[f.Name]; ok {
+		panic(fmt.Sprintf("profile format %q already registered", f.Name))
+	}
+	profileFormatRegistry[f.Name] = f
+}
+
+// ListProfileFormats returns the list of registered profile formats, sorted
+// by name.
+func ListProfileFormats() []ProfileFormat {
+	formats := make([]ProfileFormat, 0, len(profileFormatRegistry))
+	for _, f := range profileFormatRegistry {
+		formats = append(formats, f)
+	}
+	slices.SortFunc(formats, func(a, b ProfileFormat) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return formats
+}
+
+// GetProfileFormat returns the registered profile format with the given name.
+func GetProfileFormat(name string) (ProfileFormat, error) {
+	f, ok := profileFormatRegistry[name]
+	if !ok {
+		return ProfileFormat{}, fmt.Errorf("unknown profile format: %q", name)
+	}
+	return f, nil
+}
+
+// DetectProfileFormat returns the registered profile format whose extensions
+// match the extension of the given path.
+func DetectProfileFormat(path string) (ProfileFormat, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range ListProfileFormats() {
+		if slices.Contains(f.Extensions, ext) {
+			return f, nil
+		}
+	}
+	return ProfileFormat{}, fmt.Errorf("unknown profile format for extension %q", ext)
+}
+
+// ParseProfile parses the profile from the data in the given format, then
+// expands and validates it. Unknown fields are rejected unless
+// [Config.AllowUnknownFields] is set. Errors at a location within the profile
+// are reported as [ProfileError]s.
+func ParseProfile(r io.Reader, format string) (*Profile, error) {
+	f, err := GetProfileFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data: %w", err)
+	}
+	p, err := f.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", strings.ToUpper(f.Name), err)
+	}
+	if !p.Config.AllowUnknownFields {
+		if errs := unknownFields(f.Name, data, ""); len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+	}
+	if err := p.Expand(); err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		setErrorPositions(err, sourcePositions(f.Name, data, ""))
+		return p, err
+	}
+	return p, nil
+}
+
+// MarshalProfile marshals the profile into the given format.
+func MarshalProfile(p *Profile, format string) ([]byte, error) {
+	f, err := GetProfileFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	if f.Marshal == nil {
+		return nil, fmt.Errorf("profile format %q does not support marshaling", f.Name)
+	}
+	return f.Marshal(p)
+}
+
+// FormatProfile formats the profile data of the given format into its
+// canonical form, which is the form that [MarshalProfile] produces. Formats
+// that support comments, such as YAML, keep the comments of the values that
+// still exist. Interpolations are not expanded, and the profile is not
+// validated.
+//
+// Profiles with unknown fields cannot be formatted, since the unknown fields
+// would be lost, even if [Config.AllowUnknownFields] is set.
+func FormatProfile(data []byte, format string) ([]byte, error) {
+	f, err := GetProfileFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	if f.Marshal == nil {
+		return nil, fmt.Errorf("profile format %q does not support marshaling", f.Name)
+	}
+
+	p, err := f.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", strings.ToUpper(f.Name), err)
+	}
+	if errs := unknownFields(f.Name, data, ""); len(errs) > 0 {
+		return nil, fmt.Errorf("cannot format a profile with unknown fields: %w", errors.Join(errs...))
+	}
+
+	var formatted []byte
+	if f.Format != nil {
+		formatted, err = f.Format(p, data)
+	} else {
+		formatted, err = f.Marshal(p)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Never return a formatted profile that means something else.
+	reparsed, err := f.Parse(bytes.NewReader(formatted))
+	if err != nil {
+		return nil, fmt.Errorf("formatted profile cannot be parsed: %w", err)
+	}
+	// Profiles without domains may parse with either nil or empty records.
+	if len(p.Records) == 0 && len(reparsed.Records) == 0 {
+		reparsed.Records = p.Records
+	}
+	if !reflect.DeepEqual(p, reparsed) {
+		return nil, errors.New("formatted profile differs from the original")
+	}
+
+	return formatted, nil
+}
@@ -1,8 +1,14 @@
 package dnsmill
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"slices"
+	"strings"
+
+	"github.com/libdns/libdns"
 )
 
 type profileMapped struct {
@@ -14,41 +20,226 @@ type mappedRootDomain struct {
 	RootDomain   Domain
 	Subdomains   DomainRecords
 	ProviderName string
+	// TTL is the default TTL for records in this zone.
+	TTL TTL
+	// PTRSources contains the forward records with PTR enabled if this zone
+	// is a reverse zone.
+	PTRSources DomainRecords
 }
 
+// mapRootDomains maps the domains of the profile to the zones that they belong
+// to. It reports every domain that cannot be mapped at once, in which case
+// the zones are returned alongside the error with those domains left out.
 func mapRootDomains(p *Profile) ([]mappedRootDomain, error) {
+	var errs []error
+
 	var rootDomains []mappedRootDomain
 	for providerName, providerConfig := range p.Providers {
 		for _, rootDomain := range providerConfig.Zones {
 			if slices.ContainsFunc(rootDomains, func(p mappedRootDomain) bool {
 				return p.RootDomain == rootDomain
 			}) {
-				return nil, fmt.Errorf("domain %q is already managed by another provider", rootDomain)
+				errs = append(errs, errorAt(providerPath(providerName)+".zones", fmt.Errorf(
+					"domain %q is already managed by another provider", rootDomain)))
+				continue
 			}
-		}
-
-		rootDomains = slices.Grow(rootDomains, len(providerConfig.Zones))
-		for _, rootDomain := range providerConfig.Zones {
 			rootDomains = append(rootDomains, mappedRootDomain{
 				RootDomain:   rootDomain,
 				Subdomains:   DomainRecords{},
 				ProviderName: providerName,
+				TTL:          providerConfig.TTL.Or(p.Config.TTL),
 			})
 		}
 	}
 
-	for domain, records := range p.Records {
-		rootDomainIx := slices.IndexFunc(rootDomains, func(d mappedRootDomain) bool {
-			_, isSubdomain := domain.SubdomainOf(d.RootDomain)
-			return isSubdomain
-		})
+	slices.SortFunc(rootDomains, func(a, b mappedRootDomain) int {
+		return strings.Compare(string(a.RootDomain), string(b.RootDomain))
+	})
+
+	for _, domain := range p.Records.sortedDomains() {
+		records := p.Records[domain]
+
+		// Delegations belong to the parent zone, not the delegated zone
+		// itself, even if the delegated zone is also managed.
+		isDelegation := records.NS != nil
+
+		rootDomainIx := -1
+		for i, d := range rootDomains {
+			subdomain, isSubdomain := domain.SubdomainOf(d.RootDomain)
+			if !isSubdomain || (isDelegation && subdomain == "") {
+				continue
+			}
+			// Prefer the most specific zone in case of nested zones.
+			if rootDomainIx == -1 || len(d.RootDomain) > len(rootDomains[rootDomainIx].RootDomain) {
+				rootDomainIx = i
+			}
+		}
 		if rootDomainIx == -1 {
-			return nil, fmt.Errorf("domain %q is not managed by any provider", domain)
+			errs = append(errs, errorAt(recordsPath(domain), fmt.Errorf(
+				"domain %q is not managed by any provider", domain)))
+			continue
+		}
+
+		if isDelegation && records.NS.FromProvider {
+			if !slices.ContainsFunc(rootDomains, func(d mappedRootDomain) bool {
+				return d.RootDomain == domain
+			}) {
+				errs = append(errs, errorAt(recordsPath(domain)+".ns", fmt.Errorf(
+					"domain %q is delegated using fromProvider but is not managed by any provider",
+					domain)))
+				continue
+			}
 		}
 
 		rootDomain := &rootDomains[rootDomainIx]
 		rootDomain.Subdomains[domain] = records
+
+		if records.PTR {
+			var hasReverseZone bool
+			for i, d := range rootDomains {
+				if d.RootDomain.IsReverseZone() {
+					if d.PTRSources == nil {
+						rootDomains[i].PTRSources = DomainRecords{}
+					}
+					rootDomains[i].PTRSources[domain] = records
+					hasReverseZone = true
+				}
+			}
+			if !hasReverseZone {
+				errs = append(errs, errorAt(recordsPath(domain)+".ptr", fmt.Errorf(
+					"domain %q has ptr enabled but no reverse zones are managed by any provider",
+					domain)))
+			}
+		}
+	}
+
+	return rootDomains, errors.Join(errs...)
+}
+
+// resolveDelegations returns the zone's records with the nameservers of
+// delegations using [Delegation.FromProvider] filled in from the providers
+// that manage the delegated zones.
+func (root mappedRootDomain) resolveDelegations(ctx context.Context, rootDomains []mappedRootDomain, providers map[string]Provider) (DomainRecords, error) {
+	subdomains := make(DomainRecords, len(root.Subdomains))
+	for domain, records := range root.Subdomains {
+		if records.NS != nil && records.NS.FromProvider {
+			childIx := slices.IndexFunc(rootDomains, func(d mappedRootDomain) bool {
+				return d.RootDomain == domain
+			})
+			if childIx == -1 {
+				return nil, fmt.Errorf("domain %q is not managed by any provider", domain)
+			}
+
+			child := rootDomains[childIx]
+			nameservers, err := lookupZoneNameservers(ctx, providers[child.ProviderName], domain)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"failed to get nameservers of %q from provider %q: %w",
+					domain, child.ProviderName, err)
+			}
+
+			delegation := *records.NS
+			delegation.Nameservers = slices.Clip(delegation.Nameservers)
+			for _, ns := range nameservers {
+				if !slices.ContainsFunc(delegation.Nameservers, func(other Nameserver) bool {
+					return other.Domain() == ns.Domain()
+				}) {
+					delegation.Nameservers = append(delegation.Nameservers, ns)
+				}
+			}
+			records.NS = &delegation
+		}
+		subdomains[domain] = records
+	}
+	return subdomains, nil
+}
+
+// convert converts the given records of the zone, which are usually the
+// result of resolveDelegations, into libdns records with the given extensions
+// attached. Records without a TTL are given the zone's TTL. The addresses that
+// the hosts of records with PTR enabled resolved to are added to ptrAddrs for
+// convertPTRs.
+func (root mappedRootDomain) convert(ctx context.Context, subdomains DomainRecords, extensions zoneExtensions, ptrAddrs map[Domain][]net.IPAddr) ([]ExtendedRecord, error) {
+	records, err := subdomains.convert(ctx, root.RootDomain, extensions, ptrAddrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert records for %q: %w", root.RootDomain, err)
+	}
+	return root.withDefaultTTL(records), nil
+}
+
+// convertPTRs converts the PTR records of the zone if it is a reverse zone.
+// The addresses of the forward records are taken from ptrAddrs, so convert
+// must have been called for every zone first. PTR records never have
+// extensions.
+func (root mappedRootDomain) convertPTRs(ptrAddrs map[Domain][]net.IPAddr) []ExtendedRecord {
+	if root.PTRSources == nil {
+		return nil
+	}
+	return root.withDefaultTTL(withoutExtensions(root.PTRSources.ConvertPTRs(root.RootDomain, ptrAddrs)))
+}
+
+// withDefaultTTL gives the records without a TTL the zone's TTL.
+func (root mappedRootDomain) withDefaultTTL(records []ExtendedRecord) []ExtendedRecord {
+	for i, record := range records {
+		if record.TTL == 0 {
+			records[i].TTL = root.TTL.Duration()
+		}
+	}
+	return records
+}
+
+// escapeDNSSDInstances escapes the DNS-SD instance names within the converted
+// records of the zone for use in a zone file.
+func (root mappedRootDomain) escapeDNSSDInstances(records []libdns.Record) {
+	for domain, rec := range root.Subdomains {
+		if rec.DNSSD != nil {
+			subdomain, _ := domain.SubdomainOf(root.RootDomain)
+			rec.DNSSD.escapeInstances(domain, subdomain, records)
+		}
+	}
+}
+
+// lookupZoneNameservers returns the nameservers listed in the NS records at
+// the apex of the given zone. Nameservers within the zone have their
+// addresses filled in from the zone's A and AAAA records for use as glue.
+func lookupZoneNameservers(ctx context.Context, provider Provider, zone Domain) ([]Nameserver, error) {
+	getter, ok := provider.(libdns.RecordGetter)
+	if !ok {
+		return nil, errors.New("provider cannot list records")
+	}
+
+	records, err := getter.GetRecords(ctx, string(zone))
+	if err != nil {
+		return nil, err
+	}
+
+	var nameservers []Nameserver
+	for _, record := range records {
+		if record.Type != "NS" || (record.Name != "" && record.Name != "@") {
+			continue
+		}
+
+		ns := Nameserver{Name: record.Value}
+		nsSubdomain, inZone := ns.Domain().SubdomainOf(zone)
+		if inZone {
+			var addrs HostAddresses
+			for _, addr := range records {
+				if (addr.Type == "A" || addr.Type == "AAAA") && addr.Name == nsSubdomain {
+					addrs = append(addrs, HostAddress{
+						Address: addr.Value,
+						Flags:   HostAddressFlags{HostAddressIP},
+					})
+				}
+			}
+			ns.Addresses = &addrs
+		}
+
+		nameservers = append(nameservers, ns)
+	}
+
+	if len(nameservers) == 0 {
+		return nil, fmt.Errorf("no NS records found at the apex of %q", zone)
 	}
 
-	return rootDomains, nil
+	return nameservers, nil
 }
@@ -0,0 +1,338 @@
+package dnsmill
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// Includes represents a list of profile files or directories to include.
+//
+// When parsing, it may either parse a single path or a list of paths.
+type Includes []string
+
+func (i *Includes) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var items []string
+	if bytes.HasPrefix(data, []byte{'['}) {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("failed to parse Includes array: %w", err)
+		}
+	} else {
+		var item string
+		if err := json.Unmarshal(data, &item); err != nil {
+			return fmt.Errorf("failed to parse Includes string: %w", err)
+		}
+		items = []string{item}
+	}
+
+	*i = items
+	return nil
+}
+
+// LoadProfile loads the profiles at the given paths and merges them into a
+// single profile, which is then checked for unknown fields, expanded and
+// validated. A path may also be a directory, in which case every file within
+// it that has the extension of a registered [ProfileFormat] is loaded. The
+// includes of each profile are resolved recursively. Each file is only loaded
+// once, even if it is included multiple times.
+//
+// Unknown fields are rejected unless the file that contains them sets
+// [Config.AllowUnknownFields]. The setting does not carry over to the files
+// that a file includes or is merged with.
+//
+// The format is used for the given paths. If it is empty, the format is
+// detected from the file extension, falling back to YAML. Included files
+// always have their format detected.
+func LoadProfile(format string, paths ...string) (*Profile, error) {
+	l := profileLoader{
+		merged:    NewProfile(),
+		loaded:    make(map[string]bool),
+		positions: make(map[string]Position),
+	}
+
+	for _, path := range paths {
+		if err := l.loadPath(path, format); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(l.unknownFields) > 0 {
+		return nil, errors.Join(l.unknownFields...)
+	}
+
+	if err := l.merged.Expand(); err != nil {
+		return nil, err
+	}
+
+	if err := l.merged.Validate(); err != nil {
+		setErrorPositions(err, l.positions)
+		return l.merged, err
+	}
+
+	return l.merged, nil
+}
+
+type profileLoader struct {
+	merged *Profile
+	loaded map[string]bool
+	// positions contains the positions of the values within the loaded
+	// files, keyed by path. The first file defining a path wins.
+	positions map[string]Position
+	// unknownFields contains the errors for the unknown fields within the
+	// loaded files that do not allow unknown fields.
+	unknownFields []error
+}
+
+// loadPath loads the profile file at the given path, or every profile file
+// within it if it is a directory.
+func (l *profileLoader) loadPath(path, format string) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !stat.IsDir() {
+		return l.load(path, format)
+	}
+
+	files, err := profileFilesInDir(path)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := l.load(file, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *profileLoader) load(path, format string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if l.loaded[abs] {
+		return nil
+	}
+	l.loaded[abs] = true
+
+	if format == "" {
+		format = "yaml"
+		if f, err := DetectProfileFormat(path); err == nil {
+			format = f.Name
+		}
+	}
+
+	profileFormat, err := GetProfileFormat(format)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	p, err := profileFormat.Parse(bytes.NewReader(data))
+	if err != nil {
+		if setErrorFile(err, path) {
+			return err
+		}
+		return fmt.Errorf("failed to parse %q: %w", path, err)
+	}
+
+	positions := sourcePositions(profileFormat.Name, data, path)
+	if !p.Config.AllowUnknownFields {
+		l.unknownFields = append(l.unknownFields, unknownFields(profileFormat.Name, data, path)...)
+	}
+
+	includes := p.Include
+	p.Include = nil
+	p.resolveFilePaths(filepath.Dir(abs))
+
+	if err := l.merged.Merge(p); err != nil {
+		if setErrorPositions(err, positions) && setErrorFile(err, path) {
+			return err
+		}
+		return fmt.Errorf("failed to merge %q: %w", path, err)
+	}
+
+	for path, pos := range positions {
+		if _, ok := l.positions[path]; !ok {
+			l.positions[path] = pos
+		}
+	}
+
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+
+		matches, err := filepath.Glob(include)
+		if err != nil {
+			return fmt.Errorf("invalid include %q in %q: %w", include, path, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("include %q in %q matches no files", include, path)
+		}
+
+		for _, match := range matches {
+			if err := l.loadPath(match, ""); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// profileFilesInDir returns the files within the directory that have the
+// extension of a registered profile format, sorted by name.
+func profileFilesInDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if _, err := DetectProfileFormat(entry.Name()); err == nil {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no profiles found in directory %q", dir)
+	}
+
+	return files, nil
+}
+
+// Merge merges the other profile into this profile. Providers are merged by
+// combining their zones, and records are merged by domain. Defining the same
+// domain in both profiles or conflicting configurations is an error. All
+// conflicts are reported at once using [errors.Join].
+func (p *Profile) Merge(other *Profile) error {
+	var errs []error
+
+	if err := p.Config.merge(other.Config); err != nil {
+		errs = append(errs, err)
+	}
+
+	p.Include = append(p.Include, other.Include...)
+
+	for _, name := range sortedKeys(other.Vars) {
+		value, ok := p.Vars[name]
+		if ok && value != other.Vars[name] {
+			errs = append(errs, errorAt("vars["+strconv.Quote(name)+"]", fmt.Errorf(
+				"variable %q is already defined with a different value", name)))
+			continue
+		}
+		if p.Vars == nil {
+			p.Vars = make(map[string]string, len(other.Vars))
+		}
+		p.Vars[name] = other.Vars[name]
+	}
+
+	for _, name := range sortedKeys(other.Hosts) {
+		hosts, ok := p.Hosts[name]
+		if ok && !reflect.DeepEqual(hosts, other.Hosts[name]) {
+			errs = append(errs, errorAt("hosts["+strconv.Quote(name)+"]", fmt.Errorf(
+				"host %q is already defined with different addresses", name)))
+			continue
+		}
+		if p.Hosts == nil {
+			p.Hosts = make(NamedHosts, len(other.Hosts))
+		}
+		p.Hosts[name] = other.Hosts[name]
+	}
+
+	for _, name := range sortedKeys(other.Providers) {
+		otherConfig := other.Providers[name]
+
+		config, ok := p.Providers[name]
+		if !ok {
+			if p.Providers == nil {
+				p.Providers = make(map[string]ProviderConfig, len(other.Providers))
+			}
+			p.Providers[name] = otherConfig
+			continue
+		}
+
+		if config.TTL != 0 && otherConfig.TTL != 0 && config.TTL != otherConfig.TTL {
+			errs = append(errs, errorAt(providerPath(name)+".ttl", fmt.Errorf(
+				"provider %q has conflicting TTLs", name)))
+		} else {
+			config.TTL = config.TTL.Or(otherConfig.TTL)
+		}
+
+		for _, zone := range otherConfig.Zones {
+			if !slices.Contains(config.Zones, zone) {
+				config.Zones = append(slices.Clip(config.Zones), zone)
+			}
+		}
+
+		p.Providers[name] = config
+	}
+
+	for _, domain := range other.Records.sortedDomains() {
+		if _, ok := p.Records[domain]; ok {
+			errs = append(errs, errorAt(recordsPath(domain), fmt.Errorf(
+				"domain %q is already defined", domain)))
+			continue
+		}
+		if p.Records == nil {
+			p.Records = make(DomainRecords, len(other.Records))
+		}
+		p.Records[domain] = other.Records[domain]
+	}
+
+	return errors.Join(errs...)
+}
+
+// merge merges the other config into this config. Fields that are set to
+// their default values are overridden by the other config, while differing
+// non-default values are a conflict. AllowUnknownFields is kept as is.
+func (c *Config) merge(other Config) error {
+	var errs []error
+	defaults := DefaultConfig()
+
+	switch {
+	case other.DuplicatePolicy == "" || other.DuplicatePolicy == defaults.DuplicatePolicy:
+	case c.DuplicatePolicy == "" || c.DuplicatePolicy == defaults.DuplicatePolicy:
+		c.DuplicatePolicy = other.DuplicatePolicy
+	case c.DuplicatePolicy != other.DuplicatePolicy:
+		errs = append(errs, fmt.Errorf(
+			"conflicting duplicatePolicy %q and %q",
+			c.DuplicatePolicy, other.DuplicatePolicy))
+	}
+
+	// AllowUnknownFields only applies to the file that sets it, so it is not
+	// merged.
+
+	switch {
+	case other.TTL == 0:
+	case c.TTL == 0:
+		c.TTL = other.TTL
+	case c.TTL != other.TTL:
+		errs = append(errs, fmt.Errorf(
+			"conflicting TTLs %s and %s",
+			c.TTL.Duration(), other.TTL.Duration()))
+	}
+
+	return errors.Join(errs...)
+}
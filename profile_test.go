@@ -1,11 +1,27 @@
 package dnsmill
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/hexops/autogold/v2"
+	"github.com/invopop/yaml"
+	"github.com/libdns/libdns"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
 // testResult tuples the result and error of a test.
@@ -38,6 +54,721 @@ func TestParseProfileAsYAML(t *testing.T) {
 	}
 }
 
+func TestParseProfileAsTOML(t *testing.T) {
+	f, err := os.Open("testdata/parse_test.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p, err := parseProfileAsTOML(f)
+	autogold.ExpectFile(t, testResult[*Profile]{p, err})
+}
+
+func TestExportRecords(t *testing.T) {
+	records := []libdns.Record{
+		{Type: "SOA", Name: "@", Value: "ns1.example.net. hostmaster.example.com. 1 7200 3600 1209600 3600", TTL: time.Hour},
+		{Type: "NS", Name: "@", Value: "ns1.example.net.", TTL: time.Hour},
+		{Type: "A", Name: "@", Value: "203.0.113.1", TTL: time.Hour},
+		{Type: "AAAA", Name: "@", Value: "2001:db8::1", TTL: time.Hour},
+		{Type: "MX", Name: "@", Value: "mail.example.com.", Priority: 10, TTL: time.Hour},
+		{Type: "TXT", Name: "@", Value: "v=spf1 mx -all", TTL: 5 * time.Minute},
+		{Type: "A", Name: "web", Value: "203.0.113.3", TTL: time.Hour},
+		{Type: "A", Name: "web", Value: "203.0.113.2", TTL: time.Hour},
+		{Type: "CNAME", Name: "www", Value: "web.example.com.", TTL: time.Hour},
+		{Type: "NS", Name: "lab", Value: "ns1.lab.example.com.", TTL: 24 * time.Hour},
+		{Type: "NS", Name: "lab", Value: "ns2.lab.example.com.", TTL: 24 * time.Hour},
+		{Type: "SRV", Name: "_sip._tcp", Value: "5060 sip.example.com.", Priority: 10, Weight: 20, TTL: time.Hour},
+	}
+
+	domainRecords, ttl := ExportRecords("example.com", records)
+
+	p := NewProfile()
+	p.Providers = map[string]ProviderConfig{"cloudflare": {Zones: Domains{"example.com"}, TTL: ttl}}
+	p.Records = domainRecords
+
+	data, err := MarshalProfile(p, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.ExpectFile(t, autogold.Raw(data))
+
+	// The exported profile must parse back into the same profile.
+	parsed, err := ParseProfileAsYAML(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal("exported profile does not parse:", err)
+	}
+	reexported, err := MarshalProfile(parsed, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reexported) != string(data) {
+		t.Errorf("exported profile does not round-trip:\n%s", reexported)
+	}
+}
+
+func TestZoneFile(t *testing.T) {
+	withTestProviders(t, ProviderFactory{
+		Name: "zonefile_test",
+		New:  func(ctx context.Context) (Provider, error) { return nil, nil },
+	})
+
+	f, err := os.Open("testdata/zone_test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zone, err := ParseZone(f, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := ImportZones("zonefile_test", zone)
+	imported, err := MarshalProfile(p, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("import", func(t *testing.T) {
+		autogold.ExpectFile(t, autogold.Raw(imported))
+	})
+
+	t.Run("render", func(t *testing.T) {
+		parsed, err := ParseProfileAsYAML(strings.NewReader(string(imported)))
+		if err != nil {
+			t.Fatal("imported profile does not parse:", err)
+		}
+
+		zones, err := parsed.RenderZones(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var rendered strings.Builder
+		for _, zone := range zones {
+			if _, err := zone.WriteTo(&rendered); err != nil {
+				t.Fatal(err)
+			}
+		}
+		autogold.ExpectFile(t, autogold.Raw(rendered.String()))
+
+		// Importing the rendered zone must give back the same profile.
+		reparsed, err := ParseZone(strings.NewReader(rendered.String()), "")
+		if err != nil {
+			t.Fatal("rendered zone does not parse:", err)
+		}
+		reimported, err := MarshalProfile(ImportZones("zonefile_test", reparsed), "yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(reimported) != string(imported) {
+			t.Errorf("rendered zone does not round-trip:\n%s", reimported)
+		}
+	})
+}
+
+func TestFormatProfile(t *testing.T) {
+	formatted, err := FormatProfile(mustReadFile(t, "testdata/format_test.yml"), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.ExpectFile(t, autogold.Raw(formatted))
+
+	reformatted, err := FormatProfile(formatted, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reformatted) != string(formatted) {
+		t.Errorf("formatting is not idempotent:\n%s", reformatted)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	withTestProviders(t, ProviderFactory{
+		Name: "convert_test",
+		New:  func(ctx context.Context) (Provider, error) { return nil, nil },
+	})
+
+	ctx := WithResolver(context.Background(), &stubResolver{
+		ips: map[string][]net.IPAddr{
+			"libdb.netlify.app.": {{IP: net.ParseIP("192.0.2.10")}, {IP: net.ParseIP("2001:db8::10")}},
+		},
+	})
+
+	for _, test := range readTestCases(t, "testdata/convert_test.yml") {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := ParseProfileAsYAML(strings.NewReader(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			zones, err := p.RenderZones(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rendered strings.Builder
+			for _, zone := range zones {
+				if _, err := zone.WriteTo(&rendered); err != nil {
+					t.Fatal(err)
+				}
+			}
+			autogold.ExpectFile(t, autogold.Raw(rendered.String()))
+		})
+	}
+}
+
+func TestDelegationFromProvider(t *testing.T) {
+	listing := &listingProvider{
+		zones: map[string][]libdns.Record{
+			"lab.libdb.so": {
+				{Type: "NS", Name: "@", Value: "ns1.lab.libdb.so."},
+				{Type: "NS", Name: "@", Value: "ns.example.net."},
+				{Type: "NS", Name: "sub", Value: "ns.example.org."},
+				{Type: "A", Name: "ns1", Value: "192.0.2.54"},
+				{Type: "AAAA", Name: "ns1", Value: "2001:db8::54"},
+				{Type: "A", Name: "www", Value: "192.0.2.80"},
+			},
+			"empty.libdb.so": {
+				{Type: "A", Name: "@", Value: "192.0.2.80"},
+			},
+		},
+	}
+	withTestProviders(t,
+		ProviderFactory{
+			Name: "delegation_parent_test",
+			New:  func(ctx context.Context) (Provider, error) { return &recordingProvider{}, nil },
+		},
+		ProviderFactory{
+			Name: "delegation_child_test",
+			New:  func(ctx context.Context) (Provider, error) { return listing, nil },
+		},
+	)
+
+	renderZones := func(t *testing.T, profile string) (string, error) {
+		p, err := ParseProfileAsYAML(strings.NewReader(profile))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		zones, err := p.RenderZones(context.Background())
+		if err != nil {
+			return "", err
+		}
+
+		var rendered strings.Builder
+		for _, zone := range zones {
+			if _, err := zone.WriteTo(&rendered); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rendered.String(), nil
+	}
+
+	t.Run("nameservers and glue", func(t *testing.T) {
+		rendered, err := renderZones(t, `
+providers:
+  delegation_parent_test: [libdb.so]
+  delegation_child_test: [lab.libdb.so]
+libdb.so: [192.0.2.1]
+lab.libdb.so:
+  ns:
+    fromProvider: true
+    nameservers: [ns.example.net., ns2.example.net.]
+`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		autogold.ExpectFile(t, autogold.Raw(rendered))
+	})
+
+	t.Run("zone without NS records", func(t *testing.T) {
+		_, err := renderZones(t, `
+providers:
+  delegation_parent_test: [libdb.so]
+  delegation_child_test: [empty.libdb.so]
+libdb.so: [192.0.2.1]
+empty.libdb.so:
+  ns: { fromProvider: true }
+`)
+		if err == nil || !strings.Contains(err.Error(), `no NS records found at the apex of "empty.libdb.so"`) {
+			t.Fatalf("expected missing NS records error, got %v", err)
+		}
+	})
+
+	t.Run("provider that cannot list records", func(t *testing.T) {
+		_, err := renderZones(t, `
+providers:
+  delegation_child_test: [libdb.so]
+  delegation_parent_test: [lab.libdb.so]
+libdb.so: [192.0.2.1]
+lab.libdb.so:
+  ns: { fromProvider: true }
+`)
+		if err == nil || !strings.Contains(err.Error(), "provider cannot list records") {
+			t.Fatalf("expected provider cannot list records error, got %v", err)
+		}
+	})
+}
+
+func TestDNSSDConvert(t *testing.T) {
+	services := DNSSDServices{
+		{Instance: "Office Printer", Service: "_ipp._tcp", Port: 631, Target: "printer.libdb.so."},
+		{Instance: "files.nas", Service: "_smb._tcp", Port: 445, Target: "nas.libdb.so.",
+			TXT: map[string]string{"path": "/srv", "model": "Xserve"}},
+	}
+
+	// Providers are given the plain instance names, which are only escaped
+	// when rendering zone files.
+	got := services.Convert("libdb.so", "", 0)
+	want := []libdns.Record{
+		{Type: "PTR", Name: "_services._dns-sd._udp", Value: "_ipp._tcp.libdb.so."},
+		{Type: "PTR", Name: "_ipp._tcp", Value: "Office Printer._ipp._tcp.libdb.so."},
+		{Type: "SRV", Name: "Office Printer._ipp._tcp", Value: "631 printer.libdb.so."},
+		{Type: "TXT", Name: "Office Printer._ipp._tcp", Value: `""`},
+		{Type: "PTR", Name: "_services._dns-sd._udp", Value: "_smb._tcp.libdb.so."},
+		{Type: "PTR", Name: "_smb._tcp", Value: "files.nas._smb._tcp.libdb.so."},
+		{Type: "SRV", Name: "files.nas._smb._tcp", Value: "445 nas.libdb.so."},
+		{Type: "TXT", Name: "files.nas._smb._tcp", Value: `"model=Xserve" "path=/srv"`},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("unexpected records:\ngot  %v\nwant %v", got, want)
+	}
+}
+
+func TestHostsResolvedOnce(t *testing.T) {
+	withTestProviders(t, ProviderFactory{
+		Name: "named_hosts_test",
+		New:  func(ctx context.Context) (Provider, error) { return nil, nil },
+	})
+
+	resolver := &stubResolver{
+		ips: map[string][]net.IPAddr{
+			"web.example.net.":  {{IP: net.ParseIP("192.0.2.10")}, {IP: net.ParseIP("2001:db8::10")}},
+			"mail.example.net.": {{IP: net.ParseIP("192.0.2.25")}},
+			"app.example.net.":  {{IP: net.ParseIP("192.0.2.80")}},
+		},
+	}
+
+	p, err := ParseProfileAsYAML(strings.NewReader(`
+hosts:
+  web: [web.example.net.]
+  mail: [mail.example.net.]
+
+providers:
+  named_hosts_test: [libdb.so, libdb.dev, 2.0.192.in-addr.arpa]
+
+libdb.so:
+  hosts: ["@web"]
+  https:
+    alpn: [h2]
+    ipv4hint: ["@web"]
+    ipv6hint: ["@web"]
+www.libdb.so: ["@web"]
+mail.libdb.so:
+  hosts: ["@mail"]
+  ptr: true
+app.libdb.so:
+  hosts: [app.example.net.]
+  ptr: true
+libdb.dev: ["@web", "@mail"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.RenderZones(WithResolver(context.Background(), resolver)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{
+		"web.example.net.":  1,
+		"mail.example.net.": 1,
+		"app.example.net.":  1,
+	}
+	if !maps.Equal(resolver.ipLookups, want) {
+		t.Errorf("unexpected lookups: got %v, want %v", resolver.ipLookups, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range readTestCases(t, "testdata/validate_test.yml") {
+		t.Run(test.name, func(t *testing.T) {
+			errs := "no errors\n"
+			if _, err := ParseProfileAsYAML(strings.NewReader(test.data)); err != nil {
+				errs = err.Error() + "\n"
+			}
+			autogold.ExpectFile(t, autogold.Raw(errs))
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	t.Setenv("DNSMILL_TEST_REPORTS", "reports@libdb.so")
+	t.Setenv("DNSMILL_TEST_MAIL_DOMAIN", "mail.libdb.so")
+
+	for _, test := range readTestCases(t, "testdata/expand_test.yml") {
+		t.Run(test.name, func(t *testing.T) {
+			var result string
+			p, err := ParseProfileAsYAML(strings.NewReader(test.data))
+			if err != nil {
+				result = "error: " + err.Error() + "\n"
+			} else {
+				b, err := MarshalProfile(p, "yaml")
+				if err != nil {
+					t.Fatal(err)
+				}
+				result = string(b)
+			}
+			autogold.ExpectFile(t, autogold.Raw(result))
+		})
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		paths []string
+	}{
+		{
+			name: "nested includes",
+			files: map[string]string{
+				"main.yml": `
+include: zones/libdb.yml
+providers:
+  cloudflare: [libdb.so]
+`,
+				"zones/libdb.yml": `
+include: [../hosts/web.yml]
+libdb.so: [192.0.2.1]
+`,
+				"hosts/web.yml": `
+www.libdb.so:
+  cname: libdb.so.
+`,
+			},
+			paths: []string{"main.yml"},
+		},
+		{
+			// The test runs from the module directory, so the paths must be
+			// resolved from the file that declares the interpolation.
+			name: "file interpolations relative to the declaring file",
+			files: map[string]string{
+				"main.yml": `
+include: zones/libdb.yml
+providers:
+  cloudflare: [libdb.so]
+vars:
+  ip: ${file:hosts/ip.txt}
+`,
+				"hosts/ip.txt": `192.0.2.1`,
+				"zones/libdb.yml": `
+libdb.so:
+  hosts: ["${var:ip}"]
+  raw:
+    - type: TXT
+      value: ${file:keys/verification.txt}
+    - type: TXT
+      value: $${file:literal}
+`,
+				"zones/keys/verification.txt": `verification=abc`,
+			},
+			paths: []string{"main.yml"},
+		},
+		{
+			name: "glob includes",
+			files: map[string]string{
+				"main.yml": `
+include: "domains/*.yml"
+providers:
+  cloudflare: [libdb.so]
+`,
+				"domains/a.yml":      `a.libdb.so: [192.0.2.1]`,
+				"domains/b.yml":      `b.libdb.so: [192.0.2.2]`,
+				"domains/ignored.md": `not a profile`,
+			},
+			paths: []string{"main.yml"},
+		},
+		{
+			name: "directories",
+			files: map[string]string{
+				"main.yml": `
+include: domains
+providers:
+  cloudflare: [libdb.so]
+`,
+				"domains/a.yml":  `a.libdb.so: [192.0.2.1]`,
+				"domains/b.json": `{"b.libdb.so": ["192.0.2.2"]}`,
+				"domains/c.toml": `"c.libdb.so" = ["192.0.2.3"]`,
+			},
+			paths: []string{"main.yml"},
+		},
+		{
+			name: "include cycles",
+			files: map[string]string{
+				"a.yml": `
+include: b.yml
+providers:
+  cloudflare: [libdb.so]
+a.libdb.so: [192.0.2.1]
+`,
+				"b.yml": `
+include: [a.yml, b.yml]
+b.libdb.so: [192.0.2.2]
+`,
+			},
+			paths: []string{"a.yml"},
+		},
+		{
+			name: "multiple paths",
+			files: map[string]string{
+				"a.yml": `
+providers:
+  cloudflare:
+    zones: [libdb.so]
+    ttl: 1h
+vars:
+  ip: 192.0.2.1
+a.libdb.so: ["${var:ip}"]
+`,
+				"b.yml": `
+providers:
+  cloudflare: [libdb.dev]
+vars:
+  ip: 192.0.2.1
+b.libdb.dev: ["${var:ip}"]
+`,
+			},
+			paths: []string{"a.yml", "b.yml"},
+		},
+		{
+			name: "conflicting domains, providers and vars",
+			files: map[string]string{
+				"a.yml": `
+include: b.yml
+providers:
+  cloudflare: { zones: [libdb.so], ttl: 1h }
+vars:
+  ip: 192.0.2.1
+libdb.so: ["${var:ip}"]
+`,
+				"b.yml": `
+providers:
+  cloudflare: { zones: [libdb.so], ttl: 5m }
+vars:
+  ip: 192.0.2.2
+libdb.so: [192.0.2.3]
+`,
+			},
+			paths: []string{"a.yml"},
+		},
+		{
+			name: "include matching no files",
+			files: map[string]string{
+				"main.yml": `include: "domains/*.yml"`,
+			},
+			paths: []string{"main.yml"},
+		},
+		{
+			name: "unknown fields in an included file",
+			files: map[string]string{
+				"main.yml": `
+include: b.yml
+config:
+  allowUnknownFields: true
+providers:
+  cloudflare: [libdb.so]
+a.libdb.so:
+  hosts: [192.0.2.1]
+  comment: allowed in this file
+`,
+				"b.yml": `
+b.libdb.so:
+  cnmae: libdb.so.
+`,
+			},
+			paths: []string{"main.yml"},
+		},
+		{
+			name: "unknown fields allowed by an included file",
+			files: map[string]string{
+				"main.yml": `
+include: b.yml
+providers:
+  cloudflare: [libdb.so]
+a.libdb.so:
+  cnmae: libdb.so.
+`,
+				"b.yml": `
+config:
+  allowUnknownFields: true
+b.libdb.so:
+  hosts: [192.0.2.2]
+  comment: allowed in this file
+`,
+			},
+			paths: []string{"main.yml"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range test.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(strings.TrimSpace(data)+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			paths := make([]string, len(test.paths))
+			for i, path := range test.paths {
+				paths[i] = filepath.Join(dir, path)
+			}
+
+			var result string
+			p, err := LoadProfile("", paths...)
+			if err != nil {
+				result = "error: " + strings.ReplaceAll(err.Error(), dir+string(filepath.Separator), "") + "\n"
+			} else {
+				b, err := MarshalProfile(p, "yaml")
+				if err != nil {
+					t.Fatal(err)
+				}
+				result = strings.ReplaceAll(string(b), dir+string(filepath.Separator), "")
+			}
+			autogold.ExpectFile(t, autogold.Raw(result))
+		})
+	}
+}
+
+func TestProfileJSONSchema(t *testing.T) {
+	// Only use the providers that the profiles below refer to, since the
+	// schema lists the names of the registered providers.
+	withTestProviders(t,
+		ProviderFactory{Name: "cloudflare"},
+		ProviderFactory{Name: "porkbun"},
+		ProviderFactory{Name: "convert_test"},
+	)
+
+	b, err := ProfileJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	autogold.ExpectFile(t, autogold.Raw(string(b)+"\n"))
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft7
+	if err := compiler.AddResource(ProfileJSONSchemaID, bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := compiler.Compile(ProfileJSONSchemaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validate := func(t *testing.T, v any) {
+		t.Helper()
+		// Round-trip the value through JSON to get the types that the
+		// validator expects.
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		if err := schema.Validate(v); err != nil {
+			t.Errorf("profile does not match the schema: %#v", err)
+		}
+	}
+
+	validateYAML := func(t *testing.T, data []byte) {
+		t.Helper()
+		// Only profiles that parse are expected to match the schema.
+		if _, err := parseProfileAsYAML(bytes.NewReader(data)); err != nil {
+			t.Skip("profile does not parse:", err)
+		}
+		if errs := unknownFields("yaml", data, ""); len(errs) > 0 {
+			t.Skip("profile has unknown fields:", errors.Join(errs...))
+		}
+		var v any
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			t.Fatal(err)
+		}
+		validate(t, v)
+	}
+
+	t.Run("example_profile.yml", func(t *testing.T) {
+		validateYAML(t, mustReadFile(t, "example_profile.yml"))
+	})
+
+	t.Run("parse_test.toml", func(t *testing.T) {
+		var v any
+		if err := toml.Unmarshal(mustReadFile(t, "testdata/parse_test.toml"), &v); err != nil {
+			t.Fatal(err)
+		}
+		validate(t, v)
+	})
+
+	for _, path := range []string{
+		"testdata/parse_test.yml",
+		"testdata/convert_test.yml",
+		"testdata/format_test.yml",
+		"testdata/validate_test.yml",
+		"testdata/expand_test.yml",
+	} {
+		for _, test := range readTestCases(t, path) {
+			t.Run(filepath.Base(path)+"/"+test.name, func(t *testing.T) {
+				validateYAML(t, []byte(test.data))
+			})
+		}
+	}
+}
+
+type testCase struct {
+	name string
+	data string
+}
+
+// readTestCases reads the test cases from the file, which are separated by
+// "---" lines and start with a "# name" comment line.
+func readTestCases(t *testing.T, path string) []testCase {
+	var tests []testCase
+	for i, test := range strings.Split(string(mustReadFile(t, path)), "---") {
+		name, data, ok := strings.Cut(strings.TrimSpace(test), "\n")
+		if !ok {
+			t.Fatalf("test %d in %s is invalid: no test name", i+1, path)
+		}
+		tests = append(tests, testCase{
+			name: strings.TrimSpace(strings.TrimPrefix(name, "#")),
+			data: strings.TrimSpace(data),
+		})
+	}
+	return tests
+}
+
+// withTestProviders replaces the provider registry with the given providers
+// until the test finishes, so that tests can be run more than once.
+func withTestProviders(t *testing.T, factories ...ProviderFactory) {
+	t.Helper()
+
+	registry := providerRegistry
+	providerRegistry = map[string]ProviderFactory{}
+	t.Cleanup(func() { providerRegistry = registry })
+
+	for _, f := range factories {
+		RegisterProvider(f)
+	}
+}
+
 func mustReadFile(t *testing.T, path string) []byte {
 	b, err := os.ReadFile(path)
 	if err != nil {
@@ -45,3 +776,417 @@ func mustReadFile(t *testing.T, path string) []byte {
 	}
 	return b
 }
+
+func TestApplyUnresolvedIncludes(t *testing.T) {
+	withTestProviders(t, ProviderFactory{
+		Name: "apply_includes_test",
+		New: func(ctx context.Context) (Provider, error) {
+			t.Error("provider must not be created for a profile with unresolved includes")
+			return nil, nil
+		},
+	})
+
+	p := NewProfile()
+	p.Include = Includes{"other.yml"}
+	p.Providers = map[string]ProviderConfig{"apply_includes_test": {Zones: Domains{"example.com"}}}
+	p.Records = DomainRecords{"example.com": {}}
+
+	err := p.Apply(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), false)
+	if !errors.Is(err, errUnresolvedIncludes) {
+		t.Fatalf("expected unresolved includes error, got %v", err)
+	}
+}
+
+func TestClampTTL(t *testing.T) {
+	factory := ProviderFactory{MinTTL: 5 * time.Minute, MaxTTL: 24 * time.Hour}
+
+	tests := []struct {
+		name    string
+		factory ProviderFactory
+		ttl     time.Duration
+		want    time.Duration
+	}{
+		{"below the minimum", factory, time.Minute, 5 * time.Minute},
+		{"at the minimum", factory, 5 * time.Minute, 5 * time.Minute},
+		{"within the range", factory, time.Hour, time.Hour},
+		{"at the maximum", factory, 24 * time.Hour, 24 * time.Hour},
+		{"above the maximum", factory, 7 * 24 * time.Hour, 24 * time.Hour},
+		{"zero", factory, 0, 0},
+		{"no minimum", ProviderFactory{MaxTTL: time.Hour}, time.Second, time.Second},
+		{"no maximum", ProviderFactory{MinTTL: time.Minute}, 7 * 24 * time.Hour, 7 * 24 * time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.factory.clampTTL(test.ttl); got != test.want {
+				t.Errorf("clampTTL(%v) = %v, want %v", test.ttl, got, test.want)
+			}
+		})
+	}
+}
+
+func TestClampedTTLs(t *testing.T) {
+	provider := &recordingProvider{}
+	withTestProviders(t, ProviderFactory{
+		Name:   "clamp_test",
+		New:    func(ctx context.Context) (Provider, error) { return provider, nil },
+		MinTTL: 5 * time.Minute,
+		MaxTTL: 24 * time.Hour,
+	})
+
+	p, err := ParseProfileAsYAML(strings.NewReader(`
+providers:
+  clamp_test:
+    zones: [libdb.so]
+    ttl: 1m
+libdb.so: [192.0.2.1]
+short.libdb.so:
+  hosts: [192.0.2.2]
+  ttl: 30s
+long.libdb.so:
+  hosts: [192.0.2.3]
+  ttl: 168h
+inrange.libdb.so:
+  hosts: [192.0.2.4]
+  ttl: 1h
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("apply", func(t *testing.T) {
+		var logs bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+		if err := p.Apply(context.Background(), logger, false); err != nil {
+			t.Fatal(err)
+		}
+
+		ttls := make(map[string]time.Duration, len(provider.records))
+		for _, record := range provider.records {
+			ttls[record.Name] = record.TTL
+		}
+		want := map[string]time.Duration{
+			"@":       5 * time.Minute,
+			"short":   5 * time.Minute,
+			"long":    24 * time.Hour,
+			"inrange": time.Hour,
+		}
+		if !maps.Equal(ttls, want) {
+			t.Errorf("applied TTLs = %v, want %v", ttls, want)
+		}
+
+		var warned []string
+		for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+			var entry struct {
+				Msg  string `json:"msg"`
+				Name string `json:"record.name"`
+			}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatal(err)
+			}
+			if entry.Msg == "record TTL is out of the provider's range, clamping" {
+				warned = append(warned, entry.Name)
+			}
+		}
+		slices.Sort(warned)
+		if want := []string{"@", "long", "short"}; !slices.Equal(warned, want) {
+			t.Errorf("warned about %v, want %v", warned, want)
+		}
+	})
+
+	t.Run("render", func(t *testing.T) {
+		zones, err := p.RenderZones(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var rendered strings.Builder
+		for _, zone := range zones {
+			if _, err := zone.WriteTo(&rendered); err != nil {
+				t.Fatal(err)
+			}
+		}
+		autogold.ExpectFile(t, autogold.Raw(rendered.String()))
+	})
+}
+
+func TestSPFLookups(t *testing.T) {
+	ctx := WithResolver(context.Background(), &stubResolver{
+		txts: map[string][]string{
+			"_spf.a.example": {"v=spf1 ip4:192.0.2.0/24 include:_spf.b.example a mx -all"},
+			"_spf.b.example": {"v=spf1 a:x.example mx:y.example exists:%{i}.z.example redirect=_spf.c.example"},
+			"_spf.c.example": {"v=spf1 ip4:192.0.2.1 ip6:2001:db8::/32"},
+			"_spf.loop":      {"v=spf1 include:_spf.loop"},
+			"_spf.none":      {"google-site-verification=abc"},
+		},
+	})
+
+	tests := []struct {
+		name string
+		spf  SPF
+		want string
+		err  string
+	}{
+		{
+			// 2 own mechanisms, 1 include, 3 within _spf.a.example, 3 within
+			// _spf.b.example and 1 for its redirect.
+			name: "at the limit",
+			spf:  SPF{A: SPFTargets{""}, MX: SPFTargets{""}, Include: []string{"_spf.a.example"}, All: SPFFail},
+			want: "v=spf1 a mx include:_spf.a.example -all",
+		},
+		{
+			name: "over the limit",
+			spf:  SPF{A: SPFTargets{"", "mail.example"}, MX: SPFTargets{""}, Include: []string{"_spf.a.example"}},
+			err:  "SPF record causes 11 DNS lookups, exceeding the limit of 10; consider using flatten",
+		},
+		{
+			name: "flattened",
+			spf:  SPF{Include: []string{"_spf.c.example"}, Flatten: true, All: SPFSoftFail},
+			want: "v=spf1 ip4:192.0.2.1 ip6:2001:db8::/32 ~all",
+		},
+		{
+			name: "include loop",
+			spf:  SPF{Include: []string{"_spf.loop"}},
+			err:  `failed to count DNS lookups of SPF include "_spf.loop": SPF include loop detected at "_spf.loop"`,
+		},
+		{
+			name: "include without SPF record",
+			spf:  SPF{Include: []string{"_spf.none"}},
+			err:  `failed to count DNS lookups of SPF include "_spf.none": no SPF record found for "_spf.none"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.spf.TXT(ctx)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q, got %q, %v", test.err, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+// stubResolver resolves names using fixed records instead of DNS.
+type stubResolver struct {
+	ips  map[string][]net.IPAddr
+	txts map[string][]string
+	mxs  map[string][]*net.MX
+
+	// ipLookups counts the IP address lookups of each host.
+	ipLookupsMu sync.Mutex
+	ipLookups   map[string]int
+}
+
+func (r *stubResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.ipLookupsMu.Lock()
+	if r.ipLookups == nil {
+		r.ipLookups = make(map[string]int)
+	}
+	r.ipLookups[host]++
+	r.ipLookupsMu.Unlock()
+
+	if ips, ok := r.ips[host]; ok {
+		return ips, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (r *stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if txts, ok := r.txts[name]; ok {
+		return txts, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *stubResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if mxs, ok := r.mxs[name]; ok {
+		return mxs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func TestApplyExtensions(t *testing.T) {
+	parseExtension := func(data json.RawMessage) (any, error) { return string(data), nil }
+
+	plain := &recordingProvider{}
+	extending := &extendingProvider{}
+	withTestProviders(t,
+		ProviderFactory{
+			Name:           "extensions_plain_test",
+			New:            func(ctx context.Context) (Provider, error) { return plain, nil },
+			ParseExtension: parseExtension,
+		},
+		ProviderFactory{
+			Name:           "extensions_test",
+			New:            func(ctx context.Context) (Provider, error) { return extending, nil },
+			ParseExtension: parseExtension,
+			ExtensionTypes: []string{"A", "AAAA", "CNAME"},
+		},
+	)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("unsupported", func(t *testing.T) {
+		p, err := ParseProfileAsYAML(strings.NewReader(`
+providers:
+  extensions_plain_test: [a.example, b.example]
+a.example: [192.0.2.1]
+b.example:
+  hosts: [192.0.2.2]
+  extensions:
+    extensions_plain_test: { proxied: true }
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = p.Apply(context.Background(), logger, false)
+		if err == nil || err.Error() != `provider "extensions_plain_test" does not support extensions` {
+			t.Fatalf("expected unsupported extensions error, got %v", err)
+		}
+		if len(plain.records) > 0 {
+			t.Errorf("records were applied before checking extensions: %v", plain.records)
+		}
+	})
+
+	t.Run("supported", func(t *testing.T) {
+		p, err := ParseProfileAsYAML(strings.NewReader(`
+providers:
+  extensions_test: [a.example]
+a.example: [192.0.2.1]
+www.a.example:
+  hosts: [192.0.2.2]
+  https:
+    alpn: [h2]
+  dmarc: { p: none }
+  dnssd:
+    instance: Web
+    service: _http._tcp
+    port: 80
+    target: www.a.example.
+  extensions:
+    extensions_test: { proxied: true }
+lab.a.example:
+  ns:
+    - name: www.lab.a.example.
+      addresses: [192.0.2.53]
+www.lab.a.example:
+  hosts: [192.0.2.54]
+  extensions:
+    extensions_test: { proxied: true }
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.Apply(context.Background(), logger, false); err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the A records at the domains themselves have extensions, not
+		// the HTTPS, DMARC and DNS-SD records of www.a.example or the glue
+		// record of www.lab.a.example, which is at the same name.
+		var got []string
+		for _, record := range extending.extended {
+			if record.Extension != `{"proxied":true}` {
+				t.Errorf("unexpected extension %v of %s record %q", record.Extension, record.Type, record.Name)
+			}
+			got = append(got, record.Type+" "+record.Name)
+		}
+		slices.Sort(got)
+		if want := []string{"A www", "A www.lab"}; !slices.Equal(got, want) {
+			t.Errorf("extended records = %v, want %v", got, want)
+		}
+
+		var plain []string
+		for _, record := range extending.records {
+			plain = append(plain, record.Type+" "+record.Name)
+		}
+		slices.Sort(plain)
+		want := []string{
+			"A @",
+			"A www.lab",
+			"HTTPS www",
+			"NS lab",
+			"PTR _http._tcp.www",
+			"PTR _services._dns-sd._udp.www",
+			"SRV Web._http._tcp.www",
+			"TXT Web._http._tcp.www",
+			"TXT _dmarc.www",
+		}
+		if !slices.Equal(plain, want) {
+			t.Errorf("plain records = %v, want %v", plain, want)
+		}
+	})
+
+	t.Run("unsupported record types", func(t *testing.T) {
+		_, err := ParseProfileAsYAML(strings.NewReader(`
+providers:
+  extensions_test: [a.example]
+a.example:
+  https:
+    alpn: [h2]
+  extensions:
+    extensions_test: { proxied: true }
+`))
+		const want = `extensions_test extensions only apply to A, AAAA, CNAME records, but there are none`
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected unsupported record types error, got %v", err)
+		}
+	})
+}
+
+// recordingProvider records the records applied to it.
+type recordingProvider struct {
+	records []libdns.Record
+}
+
+func (p *recordingProvider) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	p.records = append(p.records, records...)
+	return records, nil
+}
+
+func (p *recordingProvider) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	p.records = append(p.records, records...)
+	return records, nil
+}
+
+// listingProvider lists the records of its zones.
+type listingProvider struct {
+	recordingProvider
+	zones map[string][]libdns.Record
+}
+
+func (p *listingProvider) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	return p.zones[zone], nil
+}
+
+// extendingProvider records the records applied to it, including those with
+// extensions.
+type extendingProvider struct {
+	recordingProvider
+	extended []ExtendedRecord
+}
+
+func (p *extendingProvider) AppendExtendedRecords(ctx context.Context, zone string, records []ExtendedRecord) ([]libdns.Record, error) {
+	p.extended = append(p.extended, records...)
+	applied := make([]libdns.Record, len(records))
+	for i, record := range records {
+		applied[i] = record.Record
+	}
+	return applied, nil
+}
+
+func (p *extendingProvider) SetExtendedRecords(ctx context.Context, zone string, records []ExtendedRecord) ([]libdns.Record, error) {
+	return p.AppendExtendedRecords(ctx, zone, records)
+}
@@ -5,6 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/libdns/libdns"
 )
@@ -16,6 +19,9 @@ import (
 type ProviderConfig struct {
 	// Zones lists the zones that are managed by the provider.
 	Zones Domains `json:"zones"`
+	// TTL is the default TTL for all records in the provider's zones. It
+	// overrides the profile's default TTL.
+	TTL TTL `json:"ttl,omitempty"`
 }
 
 func (c *ProviderConfig) UnmarshalJSON(data []byte) error {
@@ -38,6 +44,17 @@ func (c *ProviderConfig) UnmarshalJSON(data []byte) error {
 	}
 }
 
+// MarshalJSON marshals the config as its list of zones if it has no TTL.
+// The list is never shortened to a single zone, which is only accepted within
+// the object form.
+func (c ProviderConfig) MarshalJSON() ([]byte, error) {
+	if c.TTL == 0 {
+		return json.Marshal([]Domain(c.Zones))
+	}
+	type alias ProviderConfig
+	return json.Marshal(alias(c))
+}
+
 // ProviderFactory allows a DNS provider to be created.
 // The factory has metadata associated with it.
 type ProviderFactory struct {
@@ -47,6 +64,44 @@ type ProviderFactory struct {
 	Name string
 	// DocURL is the URL to the documentation of the DNS provider.
 	DocURL string
+	// MinTTL is the minimum TTL that the DNS provider accepts. Records with a
+	// lower TTL are clamped to this value. A zero value means no minimum.
+	MinTTL time.Duration
+	// MaxTTL is the maximum TTL that the DNS provider accepts. Records with a
+	// higher TTL are clamped to this value. A zero value means no maximum.
+	MaxTTL time.Duration
+	// ParseExtension parses and validates the provider-specific options of
+	// records, which are given in [Records.Extensions] under the name of the
+	// provider. The returned value is passed to the provider as
+	// [ExtendedRecord.Extension], so the provider must implement
+	// [RecordExtender]. If nil, the provider does not support extensions.
+	ParseExtension func(data json.RawMessage) (any, error)
+	// ExtensionTypes lists the record types that extensions apply to. Records
+	// of other types are applied without them, even if their domain has
+	// extensions. If empty, extensions apply to records of any type.
+	ExtensionTypes []string
+}
+
+// extendsType returns true if extensions apply to records of the given type.
+func (f ProviderFactory) extendsType(recordType string) bool {
+	return len(f.ExtensionTypes) == 0 || slices.ContainsFunc(f.ExtensionTypes, func(t string) bool {
+		return strings.EqualFold(t, recordType)
+	})
+}
+
+// clampTTL clamps the given TTL to the range accepted by the DNS provider.
+// A zero TTL is returned as-is, since it means the provider's default TTL.
+func (f ProviderFactory) clampTTL(ttl time.Duration) time.Duration {
+	if ttl == 0 {
+		return 0
+	}
+	if f.MinTTL != 0 && ttl < f.MinTTL {
+		return f.MinTTL
+	}
+	if f.MaxTTL != 0 && ttl > f.MaxTTL {
+		return f.MaxTTL
+	}
+	return ttl
 }
 
 // Provider describes a DNS provider. It must be safe for concurrent use.
@@ -3,11 +3,15 @@
 //   - `CLOUDFLARE_API_TOKEN`: The API token for the Cloudflare account. Make
 //     sure to use a scoped API token, not a global API key. It will need two
 //     permissions: Zone-Zone-Read and Zone-DNS-Edit.
+//
+// Records may be given Cloudflare-specific options under
+// `extensions.cloudflare`; see [Extension].
 package cloudflare
 
 import (
 	"context"
 	"os"
+	"time"
 
 	"github.com/libdns/cloudflare"
 	"libdb.so/dnsmill"
@@ -16,9 +20,13 @@ import (
 
 func init() {
 	dnsmill.RegisterProvider(dnsmill.ProviderFactory{
-		New:    newProvider,
-		Name:   "cloudflare",
-		DocURL: "https://pkg.go.dev/libdb.so/dnsmill/providers/cloudflare",
+		New:            newProvider,
+		Name:           "cloudflare",
+		DocURL:         "https://pkg.go.dev/libdb.so/dnsmill/providers/cloudflare",
+		MinTTL:         60 * time.Second,
+		MaxTTL:         86400 * time.Second,
+		ParseExtension: parseExtension,
+		ExtensionTypes: []string{"A", "AAAA", "CNAME", "MX", "NS", "PTR", "TXT"},
 	})
 }
 
@@ -27,7 +35,25 @@ func newProvider(context.Context) (dnsmill.Provider, error) {
 		return nil, err
 	}
 
-	return &cloudflare.Provider{
+	p := &cloudflare.Provider{
 		APIToken: os.Getenv("CLOUDFLARE_API_TOKEN"),
-	}, nil
+	}
+
+	// Only claim to support extensions if the libdns provider can apply
+	// them, so that profiles using them are rejected before anything is
+	// applied otherwise.
+	if options, ok := any(p).(recordOptionsProvider); ok {
+		return &provider{Provider: p, options: options}, nil
+	}
+	return p, nil
+}
+
+var _ dnsmill.RecordExtender = (*provider)(nil)
+
+// provider wraps the libdns Cloudflare provider to support [Extension].
+// Records with extensions are applied by the libdns provider together with
+// their options.
+type provider struct {
+	*cloudflare.Provider
+	options recordOptionsProvider
 }
@@ -0,0 +1,133 @@
+package cloudflare
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/libdns/libdns"
+	"libdb.so/dnsmill"
+)
+
+// Extension contains the Cloudflare-specific options of records. It is given
+// under `extensions.cloudflare` of the records, for example:
+//
+//	www.libdb.so:
+//	  hosts: [1.2.3.4]
+//	  extensions:
+//	    cloudflare:
+//	      proxied: true
+//	      comment: managed by dnsmill
+//
+// Records with options are created together with them, so proxied records
+// never resolve to the origin. Only A, AAAA, CNAME, MX, NS, PTR and TXT
+// records have the options; records of other types at the same domain are
+// applied without them.
+//
+// The options are applied through the libdns Cloudflare provider, which must
+// support them. Otherwise, profiles that use them are rejected.
+type Extension struct {
+	// Proxied controls whether the records are proxied through Cloudflare.
+	// It only applies to A, AAAA and CNAME records. If nil, existing records
+	// are left as they are, and new records are not proxied.
+	Proxied *bool `json:"proxied,omitempty"`
+	// Comment is a comment attached to the records. If nil, existing records
+	// are left as they are, and new records have no comment.
+	Comment *string `json:"comment,omitempty"`
+}
+
+func parseExtension(data json.RawMessage) (any, error) {
+	var ext Extension
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&ext); err != nil {
+		return nil, err
+	}
+
+	return ext, nil
+}
+
+func (e Extension) equal(other Extension) bool {
+	return ptrEqual(e.Proxied, other.Proxied) && ptrEqual(e.Comment, other.Comment)
+}
+
+func ptrEqual[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// recordOptionsProvider is implemented by the libdns Cloudflare provider if it
+// can apply records together with Cloudflare-specific options, which the
+// libdns interfaces have no notion of. Options that are nil are left as they
+// are on existing records.
+type recordOptionsProvider interface {
+	// AppendRecordsWithOptions is like AppendRecords, but it creates the
+	// records with the given options.
+	AppendRecordsWithOptions(ctx context.Context, zone string, records []libdns.Record, proxied *bool, comment *string) ([]libdns.Record, error)
+	// SetRecordsWithOptions is like SetRecords, but it creates or updates the
+	// records with the given options.
+	SetRecordsWithOptions(ctx context.Context, zone string, records []libdns.Record, proxied *bool, comment *string) ([]libdns.Record, error)
+}
+
+// AppendExtendedRecords implements [dnsmill.RecordExtender].
+func (p *provider) AppendExtendedRecords(ctx context.Context, zone string, records []dnsmill.ExtendedRecord) ([]libdns.Record, error) {
+	return applyGrouped(records, func(records []libdns.Record, ext Extension) ([]libdns.Record, error) {
+		return p.options.AppendRecordsWithOptions(ctx, zone, records, ext.Proxied, ext.Comment)
+	})
+}
+
+// SetExtendedRecords implements [dnsmill.RecordExtender].
+func (p *provider) SetExtendedRecords(ctx context.Context, zone string, records []dnsmill.ExtendedRecord) ([]libdns.Record, error) {
+	return applyGrouped(records, func(records []libdns.Record, ext Extension) ([]libdns.Record, error) {
+		return p.options.SetRecordsWithOptions(ctx, zone, records, ext.Proxied, ext.Comment)
+	})
+}
+
+// recordGroup is a group of records that have the same options.
+type recordGroup struct {
+	ext     Extension
+	records []libdns.Record
+}
+
+// applyGrouped groups the records by their options and applies each group
+// using apply, returning all applied records.
+func applyGrouped(records []dnsmill.ExtendedRecord, apply func([]libdns.Record, Extension) ([]libdns.Record, error)) ([]libdns.Record, error) {
+	var groups []recordGroup
+	for _, record := range records {
+		ext, ok := record.Extension.(Extension)
+		if !ok {
+			return nil, fmt.Errorf(
+				"unexpected extension of type %T for %s record %q",
+				record.Extension, record.Type, record.Name)
+		}
+
+		switch record.Type {
+		case "A", "AAAA", "CNAME":
+		default:
+			// Only these records can be proxied.
+			ext.Proxied = nil
+		}
+
+		i := slices.IndexFunc(groups, func(g recordGroup) bool { return g.ext.equal(ext) })
+		if i == -1 {
+			groups = append(groups, recordGroup{ext: ext})
+			i = len(groups) - 1
+		}
+		groups[i].records = append(groups[i].records, record.Record)
+	}
+
+	var applied []libdns.Record
+	for _, group := range groups {
+		recs, err := apply(group.records, group.ext)
+		applied = append(applied, recs...)
+		if err != nil {
+			return applied, err
+		}
+	}
+	return applied, nil
+}
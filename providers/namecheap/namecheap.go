@@ -20,6 +20,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/libdns/namecheap"
 	"libdb.so/dnsmill"
@@ -31,6 +32,8 @@ func init() {
 		New:    newProvider,
 		Name:   "namecheap",
 		DocURL: "https://pkg.go.dev/libdb.so/dnsmill/providers/namecheap",
+		MinTTL: 60 * time.Second,
+		MaxTTL: 60000 * time.Second,
 	})
 }
 
@@ -7,6 +7,7 @@ package porkbun
 import (
 	"context"
 	"os"
+	"time"
 
 	"github.com/libdns/porkbun"
 	"libdb.so/dnsmill"
@@ -18,6 +19,7 @@ func init() {
 		New:    newProvider,
 		Name:   "porkbun",
 		DocURL: "https://pkg.go.dev/libdb.so/dnsmill/providers/porkbun",
+		MinTTL: 600 * time.Second,
 	})
 }
 
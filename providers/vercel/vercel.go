@@ -7,6 +7,7 @@ package vercel
 import (
 	"context"
 	"os"
+	"time"
 
 	"github.com/libdns/vercel"
 	"libdb.so/dnsmill"
@@ -18,6 +19,7 @@ func init() {
 		New:    newProvider,
 		Name:   "vercel",
 		DocURL: "https://pkg.go.dev/libdb.so/dnsmill/providers/vercel",
+		MinTTL: 60 * time.Second,
 	})
 }
 
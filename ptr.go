@@ -0,0 +1,68 @@
+package dnsmill
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// IsReverseZone returns true if the domain is within the in-addr.arpa or
+// ip6.arpa reverse DNS trees.
+func (d Domain) IsReverseZone() bool {
+	_, v4 := d.SubdomainOf("in-addr.arpa")
+	_, v6 := d.SubdomainOf("ip6.arpa")
+	return v4 || v6
+}
+
+// ReverseDomain returns the reverse DNS name of the given IP address, such as
+// "1.2.0.192.in-addr.arpa" for 192.0.2.1 or the nibble form under ip6.arpa
+// for IPv6 addresses.
+func ReverseDomain(ip net.IP) Domain {
+	if v4 := ip.To4(); v4 != nil {
+		return Domain(fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0]))
+	}
+
+	v6 := ip.To16()
+	nibbles := make([]string, 0, len(v6)*2+1)
+	for i := len(v6) - 1; i >= 0; i-- {
+		nibbles = append(nibbles,
+			strconv.FormatUint(uint64(v6[i]&0x0F), 16),
+			strconv.FormatUint(uint64(v6[i]>>4), 16))
+	}
+	nibbles = append(nibbles, "ip6.arpa")
+	return Domain(strings.Join(nibbles, "."))
+}
+
+// ConvertPTRs converts the addresses of the given forward records that have
+// PTR enabled into a list of PTR [libdns.Record]s within the given reverse
+// zone. The addresses are taken from addrs, which maps the forward domains to
+// the addresses that their hosts were resolved to when converting them, so
+// that they are not resolved again. Addresses outside of the reverse zone are
+// skipped.
+func (r DomainRecords) ConvertPTRs(reverseZone Domain, addrs map[Domain][]net.IPAddr) []libdns.Record {
+	var records []libdns.Record
+
+	for domain, rec := range r {
+		if !rec.PTR {
+			continue
+		}
+
+		for _, addr := range addrs[domain] {
+			subdomain, ok := ReverseDomain(addr.IP).SubdomainOf(reverseZone)
+			if !ok || subdomain == "" {
+				continue
+			}
+			records = append(records, libdns.Record{
+				Type:  "PTR",
+				Name:  subdomain,
+				Value: string(domain) + ".",
+				TTL:   rec.TTL.Duration(),
+			})
+		}
+	}
+
+	return records
+}
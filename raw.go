@@ -0,0 +1,117 @@
+package dnsmill
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// RawRecords represents a list of raw DNS records of arbitrary types.
+//
+// When parsing, it may either parse a single raw record or a list of raw
+// records.
+type RawRecords []RawRecord
+
+func (r *RawRecords) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var items []RawRecord
+	if bytes.HasPrefix(data, []byte{'['}) {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("failed to parse RawRecords array: %w", err)
+		}
+	} else {
+		var item RawRecord
+		if err := json.Unmarshal(data, &item); err != nil {
+			return fmt.Errorf("failed to parse RawRecords object: %w", err)
+		}
+		items = []RawRecord{item}
+	}
+
+	*r = items
+	return nil
+}
+
+// MarshalJSON marshals a single raw record as an object and multiple raw
+// records as an array.
+func (r RawRecords) MarshalJSON() ([]byte, error) {
+	if len(r) == 1 {
+		return json.Marshal(r[0])
+	}
+	return json.Marshal([]RawRecord(r))
+}
+
+// Convert converts the raw records assigned to the given subdomain into a
+// list of [libdns.Record]s. The TTL is used for records without their own
+// TTL.
+func (r RawRecords) Convert(subdomain string, ttl TTL) []libdns.Record {
+	records := make([]libdns.Record, len(r))
+	for i, raw := range r {
+		records[i] = libdns.Record{
+			Type:     raw.Type,
+			Name:     subdomain,
+			Value:    raw.Value,
+			TTL:      raw.TTL.Or(ttl).Duration(),
+			Priority: raw.Priority,
+			Weight:   raw.Weight,
+			Target:   raw.Target,
+		}
+	}
+	return records
+}
+
+// RawRecord is a DNS record of an arbitrary type that is passed through to
+// the DNS provider unchanged. It is an escape hatch for record types that
+// dnsmill does not model, such as LOC, NAPTR, URI or provider-specific types.
+type RawRecord struct {
+	// Type is the RR type mnemonic, such as "LOC" or "TYPE65534". It is
+	// uppercased when parsed.
+	Type string `json:"type"`
+	// Value is the record data as it would appear in a zone file, excluding
+	// the priority. It may be empty if the record has a target.
+	Value string `json:"value,omitempty"`
+	// TTL is the TTL of the record. If zero, the TTL of the [Records] is
+	// used.
+	TTL TTL `json:"ttl,omitempty"`
+	// Priority is the priority of the record for types that have one, such
+	// as MX, SRV and URI records.
+	Priority uint `json:"priority,omitempty"`
+	// Weight is the weight of the record for types that have one, such as
+	// SRV and URI records.
+	Weight uint `json:"weight,omitempty"`
+	// Target is the target name of the record for types that have one, such
+	// as HTTPS and SVCB records, in which case Value only contains the
+	// parameters.
+	Target string `json:"target,omitempty"`
+}
+
+var rrTypeRegex = regexp.MustCompile(`^[A-Z][A-Z0-9-]*$`)
+
+func (r *RawRecord) UnmarshalJSON(data []byte) error {
+	type alias RawRecord
+	var raw alias
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to parse RawRecord: %w", err)
+	}
+
+	raw.Type = strings.ToUpper(raw.Type)
+	if raw.Type == "" {
+		return errors.New("raw record must have a type")
+	}
+	if !rrTypeRegex.MatchString(raw.Type) {
+		return fmt.Errorf("invalid raw record type %q: not a valid RR type mnemonic", raw.Type)
+	}
+	if raw.Value == "" && raw.Target == "" {
+		return fmt.Errorf("raw %s record must have a value", raw.Type)
+	}
+
+	*r = RawRecord(raw)
+	return nil
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"reflect"
+	"strings"
 
 	"github.com/libdns/libdns"
 )
@@ -23,8 +26,82 @@ type Records struct {
 	// as A records and IPv6 addresses being handled as AAAA records.
 	Hosts *HostAddresses `json:"hosts,omitempty"`
 
-	// CNAME represents a single CNAME record.
+	// CNAME represents a single CNAME record. It cannot be used at the apex of
+	// a zone; use Alias instead.
 	CNAME *string `json:"cname,omitempty"`
+
+	// Alias represents an ALIAS record, which is resolved by dnsmill into A
+	// and AAAA records when the profile is applied. Unlike CNAME, it can be
+	// used at the apex of a zone, such as for pointing a root domain to a
+	// hosting platform.
+	Alias *string `json:"alias,omitempty"`
+
+	// NS represents NS records that delegate the subdomain to other
+	// nameservers. Glue records are automatically created for nameservers
+	// within the delegated subdomain.
+	NS *Delegation `json:"ns,omitempty"`
+
+	// HTTPS represents HTTPS records, which are SVCB records specific to the
+	// HTTPS scheme.
+	HTTPS ServiceBindings `json:"https,omitempty"`
+
+	// SVCB represents generic SVCB records.
+	SVCB ServiceBindings `json:"svcb,omitempty"`
+
+	// TLSA represents TLSA records for DANE. The association data may be
+	// computed from certificate files when the records are converted.
+	TLSA TLSARecords `json:"tlsa,omitempty"`
+
+	// SSHFP represents SSHFP records that are generated from OpenSSH public
+	// key files, such as the host keys of the machine running dnsmill.
+	SSHFP *SSHFP `json:"sshfp,omitempty"`
+
+	// DKIM represents DKIM TXT records that are derived from key files and
+	// placed at `<selector>._domainkey.<domain>`.
+	DKIM DKIMKeys `json:"dkim,omitempty"`
+
+	// SPF represents a structured SPF record that is rendered into a TXT
+	// record.
+	SPF *SPF `json:"spf,omitempty"`
+
+	// DMARC represents a DMARC policy record placed at `_dmarc.<domain>`.
+	DMARC *DMARC `json:"dmarc,omitempty"`
+
+	// MTASTS represents an MTA-STS policy record placed at
+	// `_mta-sts.<domain>`.
+	MTASTS *MTASTS `json:"mtaSts,omitempty"`
+
+	// TLSRPT represents an SMTP TLS reporting record placed at
+	// `_smtp._tls.<domain>`.
+	TLSRPT *TLSRPT `json:"tlsRpt,omitempty"`
+
+	// DNSSD represents DNS-SD service instances advertised within the
+	// domain. Each service instance expands into PTR, SRV and TXT records,
+	// and its service type is enumerated under `_services._dns-sd._udp`.
+	DNSSD DNSSDServices `json:"dnssd,omitempty"`
+
+	// Preset represents presets of records for common setups, such as email
+	// hosting services. See [RegisterPreset] for registering custom presets.
+	Preset PresetConfigs `json:"preset,omitempty"`
+
+	// Raw represents records of arbitrary types that are passed through to
+	// the DNS provider unchanged, for types that dnsmill does not model.
+	Raw RawRecords `json:"raw,omitempty"`
+
+	// PTR, if true, creates PTR records for the addresses of Hosts in the
+	// reverse zones (in-addr.arpa or ip6.arpa) that are managed in the same
+	// profile. Addresses outside of the managed reverse zones are skipped.
+	PTR bool `json:"ptr,omitempty"`
+
+	// Extensions contains provider-specific options of the records at the
+	// domain, keyed by the name of the provider, such as whether the records
+	// are proxied by Cloudflare. See each provider's documentation for the
+	// supported options.
+	Extensions ProviderExtensions `json:"extensions,omitempty"`
+
+	// TTL is the TTL of the records. If zero, the TTL is inherited from the
+	// zone's [ProviderConfig] or the profile's [Config].
+	TTL TTL `json:"ttl,omitempty"`
 }
 
 func (r *Records) UnmarshalJSON(data []byte) error {
@@ -53,6 +130,24 @@ func (r *Records) UnmarshalJSON(data []byte) error {
 			return errors.New("invalid JSON: expected either hosts or cname field, not both")
 		}
 
+		if records.PTR && records.Hosts == nil {
+			return errors.New("invalid JSON: ptr field requires the hosts field")
+		}
+
+		fields := Records(records).fields()
+
+		if records.Alias != nil && (records.Hosts != nil || records.CNAME != nil) {
+			return errors.New("invalid JSON: alias field cannot be combined with hosts or cname fields")
+		}
+
+		if records.NS != nil && len(fields) > 1 {
+			return errors.New("invalid JSON: ns field cannot be combined with other records")
+		}
+
+		if records.CNAME != nil && len(fields) > 1 {
+			return errors.New("invalid JSON: cname field cannot be combined with other records")
+		}
+
 		*r = Records(records)
 	default:
 		return errors.New("invalid JSON: expected string, array, or object")
@@ -61,41 +156,244 @@ func (r *Records) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalJSON marshals the records as their host addresses if they only have
+// hosts, or as an object otherwise.
+func (r Records) MarshalJSON() ([]byte, error) {
+	if r.Hosts != nil && reflect.DeepEqual(r, Records{Hosts: r.Hosts}) {
+		return json.Marshal(r.Hosts)
+	}
+	type alias Records
+	return json.Marshal(alias(r))
+}
+
+// fields returns the JSON names of the record fields that are set.
+func (r Records) fields() []string {
+	var fields []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"hosts", r.Hosts != nil},
+		{"cname", r.CNAME != nil},
+		{"alias", r.Alias != nil},
+		{"ns", r.NS != nil},
+		{"https", r.HTTPS != nil},
+		{"svcb", r.SVCB != nil},
+		{"tlsa", r.TLSA != nil},
+		{"sshfp", r.SSHFP != nil},
+		{"spf", r.SPF != nil},
+		{"preset", r.Preset != nil},
+		{"raw", r.Raw != nil},
+	} {
+		if f.set {
+			fields = append(fields, f.name)
+		}
+	}
+	return fields
+}
+
+// ownTypes returns the types of the records that the records are converted
+// into at the domain itself. Records that are derived from them at other
+// names, such as DKIM and DNS-SD records, and presets are not included.
+func (r Records) ownTypes() []string {
+	var types []string
+	if r.Hosts != nil || r.Alias != nil {
+		types = append(types, "A", "AAAA")
+	}
+	for _, t := range []struct {
+		name string
+		set  bool
+	}{
+		{"CNAME", r.CNAME != nil},
+		{"NS", r.NS != nil},
+		{"HTTPS", r.HTTPS != nil},
+		{"SVCB", r.SVCB != nil},
+		{"TLSA", r.TLSA != nil},
+		{"SSHFP", r.SSHFP != nil},
+		{"TXT", r.SPF != nil},
+	} {
+		if t.set {
+			types = append(types, t.name)
+		}
+	}
+	for _, raw := range r.Raw {
+		types = append(types, strings.ToUpper(raw.Type))
+	}
+	return types
+}
+
+// validate validates the values of the records of the given domain that may
+// contain interpolations. Unlike the checks done when parsing, it must be
+// called after [Profile.Expand].
+func (r *Records) validate(domain Domain) []error {
+	var errs []error
+	add := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, errorAt(recordsPath(domain)+"."+field, err))
+		}
+	}
+
+	for _, binding := range r.HTTPS {
+		add("https", binding.Validate())
+	}
+	for _, binding := range r.SVCB {
+		add("svcb", binding.Validate())
+	}
+	for _, tlsa := range r.TLSA {
+		add("tlsa", tlsa.Validate())
+	}
+	for _, key := range r.DKIM {
+		add("dkim", key.Validate())
+	}
+	if r.SPF != nil {
+		add("spf", r.SPF.Validate())
+	}
+	if r.DMARC != nil {
+		add("dmarc", r.DMARC.Validate())
+	}
+	if r.TLSRPT != nil {
+		add("tlsRpt", r.TLSRPT.Validate())
+	}
+
+	return errs
+}
+
 // Convert converts the records assigned to the given subdomain into a list of
 // [libdns.Record]s.
 func (r *Records) Convert(ctx context.Context, subdomain string) ([]libdns.Record, error) {
+	records, _, err := r.convert(ctx, subdomain)
+	return records, err
+}
+
+// convert is like Convert, but it also returns the addresses that the hosts
+// resolved to, so that PTR records can be built without resolving them again.
+func (r *Records) convert(ctx context.Context, subdomain string) ([]libdns.Record, []net.IPAddr, error) {
 	if subdomain == "" {
 		subdomain = "@"
 	}
 
+	if r.CNAME != nil && subdomain == "@" {
+		return nil, nil, errors.New("CNAME records are not allowed at the zone apex, use alias instead")
+	}
+
 	var records []libdns.Record
+	var hostAddrs []net.IPAddr
 
 	switch {
 	case r.Hosts != nil:
 		addrs, err := r.Hosts.ResolveIPs(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to resolve hosts: %w", err)
-		}
-		for _, addr := range addrs {
-			t := "AAAA"
-			if v4 := addr.IP.To4(); v4 != nil {
-				t = "A"
-			}
-			records = append(records, libdns.Record{
-				Type:  t,
-				Name:  subdomain,
-				Value: addr.String(),
-			})
+			return nil, nil, fmt.Errorf("failed to resolve hosts: %w", err)
 		}
+		records = convertHostIPs(addrs, subdomain, r.TTL)
+		hostAddrs = addrs
 	case r.CNAME != nil:
 		records = []libdns.Record{
 			{
 				Type:  "CNAME",
 				Name:  subdomain,
 				Value: *r.CNAME,
+				TTL:   r.TTL.Duration(),
 			},
 		}
+	case r.Alias != nil:
+		addrs, err := HostAddress{Address: *r.Alias}.resolveAsHostname(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve alias: %w", err)
+		}
+		if len(addrs) == 0 {
+			return nil, nil, fmt.Errorf("alias %q resolved to no addresses", *r.Alias)
+		}
+		records = convertHostIPs(addrs, subdomain, r.TTL)
+	case r.NS != nil:
+		records = r.NS.Convert(subdomain, r.TTL)
 	}
 
-	return records, nil
+	if r.HTTPS != nil {
+		recs, err := r.HTTPS.Convert(ctx, "HTTPS", subdomain, r.TTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, recs...)
+	}
+
+	if r.SVCB != nil {
+		recs, err := r.SVCB.Convert(ctx, "SVCB", subdomain, r.TTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, recs...)
+	}
+
+	if r.TLSA != nil {
+		recs, err := r.TLSA.Convert(subdomain, r.TTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, recs...)
+	}
+
+	if r.SSHFP != nil {
+		recs, err := r.SSHFP.Convert(subdomain, r.TTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, recs...)
+	}
+
+	if r.DKIM != nil {
+		recs, err := r.DKIM.Convert(subdomain, r.TTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, recs...)
+	}
+
+	if r.SPF != nil {
+		recs, err := r.SPF.Convert(ctx, subdomain, r.TTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, recs...)
+	}
+
+	if r.DMARC != nil {
+		records = append(records, r.DMARC.Convert(subdomain, r.TTL)...)
+	}
+
+	if r.MTASTS != nil {
+		recs, err := r.MTASTS.Convert(subdomain, r.TTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, recs...)
+	}
+
+	if r.TLSRPT != nil {
+		records = append(records, r.TLSRPT.Convert(subdomain, r.TTL)...)
+	}
+
+	if r.Raw != nil {
+		records = append(records, r.Raw.Convert(subdomain, r.TTL)...)
+	}
+
+	return records, hostAddrs, nil
+}
+
+// convertHostIPs converts the IP addresses into A and AAAA [libdns.Record]s.
+func convertHostIPs(addrs []net.IPAddr, subdomain string, ttl TTL) []libdns.Record {
+	records := make([]libdns.Record, 0, len(addrs))
+	for _, addr := range addrs {
+		t := "AAAA"
+		if v4 := addr.IP.To4(); v4 != nil {
+			t = "A"
+		}
+		records = append(records, libdns.Record{
+			Type:  t,
+			Name:  subdomain,
+			Value: addr.String(),
+			TTL:   ttl.Duration(),
+		})
+	}
+	return records
 }
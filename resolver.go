@@ -0,0 +1,31 @@
+package dnsmill
+
+import (
+	"context"
+	"net"
+)
+
+// Resolver resolves DNS names. [net.Resolver] implements it.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+type resolverKey struct{}
+
+// WithResolver returns a context that resolves the DNS names needed to convert
+// records, such as hostnames and included SPF records, using the given
+// resolver instead of [net.DefaultResolver].
+func WithResolver(ctx context.Context, r Resolver) context.Context {
+	return context.WithValue(ctx, resolverKey{}, r)
+}
+
+// resolverFrom returns the resolver of the context, or [net.DefaultResolver]
+// if it has none.
+func resolverFrom(ctx context.Context) Resolver {
+	if r, ok := ctx.Value(resolverKey{}).(Resolver); ok {
+		return r
+	}
+	return net.DefaultResolver
+}
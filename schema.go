@@ -0,0 +1,406 @@
+package dnsmill
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ProfileJSONSchemaID is the $id of the JSON Schema returned by
+// [ProfileJSONSchema].
+const ProfileJSONSchemaID = "https://libdb.so/dnsmill/profile.schema.json"
+
+// jsonSchema is a JSON Schema object.
+type jsonSchema = map[string]any
+
+// ProfileJSONSchema returns a JSON Schema (draft-07) describing the profile
+// format, including the shorthand forms that are accepted when parsing and
+// the names of the registered providers and presets. It is meant for editors
+// and language servers to validate profiles while they are written; the
+// schema is hand-curated, since the shorthand forms cannot be derived from
+// the Go types.
+//
+// The schema only describes the syntax of the profile. [Profile.Validate] is
+// still needed to check the records against each other.
+func ProfileJSONSchema() ([]byte, error) {
+	return json.MarshalIndent(profileJSONSchema(), "", "  ")
+}
+
+func profileJSONSchema() jsonSchema {
+	return jsonSchema{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"$id":         ProfileJSONSchemaID,
+		"title":       "dnsmill profile",
+		"description": "A dnsmill profile. Every property that is not a known field is a domain name mapped to its records, unless records is given.",
+		"type":        "object",
+		"properties": jsonSchema{
+			"include": oneOrMany(jsonSchema{
+				"type":        "string",
+				"description": "A profile file, directory or glob to include, relative to this file.",
+			}),
+			"vars": jsonSchema{
+				"type":                 "object",
+				"description":          "Variables usable as ${var:name} within string values.",
+				"additionalProperties": jsonSchema{"type": "string"},
+			},
+			"hosts": jsonSchema{
+				"type":                 "object",
+				"description":          "Named hosts that records can reference as @name.",
+				"additionalProperties": ref("hostAddresses"),
+			},
+			"config":    ref("config"),
+			"providers": providersSchema(),
+			"records": jsonSchema{
+				"type":                 "object",
+				"description":          "Map of domain names to their records.",
+				"additionalProperties": ref("records"),
+			},
+		},
+		"additionalProperties": ref("records"),
+		"definitions":          schemaDefinitions(),
+	}
+}
+
+func providersSchema() jsonSchema {
+	s := jsonSchema{
+		"type":        "object",
+		"description": "Map of providers to the zones that they manage.",
+		"additionalProperties": jsonSchema{
+			"anyOf": []any{
+				ref("domains"),
+				object(jsonSchema{
+					"zones": ref("domains"),
+					"ttl":   ref("ttl"),
+				}, "zones"),
+			},
+		},
+	}
+	if names := providerNames(); len(names) > 0 {
+		s["propertyNames"] = jsonSchema{"enum": names}
+	}
+	return s
+}
+
+func schemaDefinitions() jsonSchema {
+	ttl := jsonSchema{
+		"description": "A number of seconds or a Go duration string such as \"1h30m\".",
+		"anyOf": []any{
+			jsonSchema{"type": "integer", "minimum": 0},
+			jsonSchema{"type": "string", "pattern": `^([0-9]+|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`},
+		},
+	}
+
+	hostAddress := jsonSchema{
+		"description": "A host address in the form [flag1[,flag2...]!]<address>, or @name to reference a named host.",
+		"anyOf": []any{
+			jsonSchema{"type": "string", "pattern": hostAddressPattern()},
+			object(jsonSchema{
+				"address": jsonSchema{"type": "string"},
+				"flags":   jsonSchema{"type": "array", "items": hostAddressFlagSchema()},
+			}),
+		},
+	}
+
+	nameserver := jsonSchema{
+		"anyOf": []any{
+			jsonSchema{"type": "string", "description": "The hostname of the nameserver."},
+			object(jsonSchema{
+				"name":      jsonSchema{"type": "string"},
+				"addresses": ref("hostAddresses"),
+			}, "name"),
+		},
+	}
+
+	delegation := jsonSchema{
+		"anyOf": []any{
+			nameserver,
+			jsonSchema{"type": "array", "items": nameserver},
+			object(jsonSchema{
+				"nameservers":  jsonSchema{"type": "array", "items": nameserver},
+				"fromProvider": jsonSchema{"type": "boolean"},
+			}),
+		},
+	}
+
+	serviceBinding := object(jsonSchema{
+		"priority":        jsonSchema{"type": "integer", "minimum": 0, "maximum": 65535, "default": 1},
+		"target":          jsonSchema{"type": "string", "default": "."},
+		"alpn":            jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}},
+		"no-default-alpn": jsonSchema{"type": "boolean"},
+		"port":            port(),
+		"ipv4hint":        ref("hostAddresses"),
+		"ipv6hint":        ref("hostAddresses"),
+		"ech":             jsonSchema{"type": "string"},
+	})
+
+	tlsa := object(jsonSchema{
+		"usage":        tlsaField("PKIX-TA", "PKIX-EE", "DANE-TA", "DANE-EE"),
+		"selector":     tlsaField("Cert", "SPKI"),
+		"matchingType": tlsaField("Full", "SHA2-256", "SHA2-512"),
+		"data":         jsonSchema{"type": "string", "pattern": interpolatable("^[0-9a-fA-F]+$")},
+		"file":         jsonSchema{"type": "string"},
+	})
+
+	sshfp := jsonSchema{
+		"anyOf": []any{
+			jsonSchema{"const": true, "description": "Use the default SSH host keys."},
+			jsonSchema{"type": "string"},
+			jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}},
+			object(jsonSchema{
+				"files": jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}},
+			}),
+		},
+	}
+
+	dkim := object(jsonSchema{
+		"selector": jsonSchema{"type": "string", "pattern": interpolatable(`^[^.\s](.*[^.\s])?$`)},
+		"file":     jsonSchema{"type": "string"},
+	}, "selector", "file")
+
+	spfTargets := jsonSchema{
+		"anyOf": []any{
+			jsonSchema{"type": "boolean", "description": "true for the domain of the record itself."},
+			jsonSchema{"type": "string"},
+			jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}},
+		},
+	}
+
+	spf := object(jsonSchema{
+		"a":       spfTargets,
+		"mx":      spfTargets,
+		"ip4":     ref("hostAddresses"),
+		"ip6":     ref("hostAddresses"),
+		"include": jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}},
+		"all":     caseInsensitiveEnum("+", "-", "~", "?", "pass", "fail", "softfail", "neutral"),
+		"flatten": jsonSchema{"type": "boolean"},
+	})
+
+	dmarcPolicy := caseInsensitiveEnum("none", "quarantine", "reject")
+	dmarcAlignment := caseInsensitiveEnum("r", "s", "relaxed", "strict")
+	dmarc := object(jsonSchema{
+		"p":     dmarcPolicy,
+		"sp":    dmarcPolicy,
+		"pct":   jsonSchema{"type": "integer", "minimum": 0, "maximum": 100},
+		"rua":   jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}},
+		"ruf":   jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}},
+		"adkim": dmarcAlignment,
+		"aspf":  dmarcAlignment,
+	}, "p")
+
+	mtaSTS := object(jsonSchema{
+		"file":   jsonSchema{"type": "string"},
+		"mode":   caseInsensitiveEnum("enforce", "testing", "none"),
+		"mx":     jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}},
+		"maxAge": ref("ttl"),
+	})
+
+	tlsRPT := object(jsonSchema{
+		"rua": jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}, "minItems": 1},
+	}, "rua")
+
+	dnssd := object(jsonSchema{
+		"instance": jsonSchema{"type": "string"},
+		"service":  jsonSchema{"type": "string", "pattern": dnssdServiceRegex.String()},
+		"port":     port(),
+		"target":   jsonSchema{"type": "string"},
+		"priority": jsonSchema{"type": "integer", "minimum": 0, "maximum": 65535},
+		"weight":   jsonSchema{"type": "integer", "minimum": 0, "maximum": 65535},
+		"txt":      jsonSchema{"type": "object", "additionalProperties": jsonSchema{"type": "string"}},
+	}, "instance", "service", "port", "target")
+
+	presetName := jsonSchema{"type": "string"}
+	if names := presetNames(); len(names) > 0 {
+		presetName["enum"] = names
+	}
+	preset := jsonSchema{
+		"anyOf": []any{
+			presetName,
+			object(jsonSchema{
+				"name":   presetName,
+				"params": jsonSchema{"type": "object", "additionalProperties": jsonSchema{"type": "string"}},
+			}, "name"),
+		},
+	}
+
+	raw := object(jsonSchema{
+		"type":     jsonSchema{"type": "string", "pattern": "^[A-Za-z][A-Za-z0-9-]*$"},
+		"value":    jsonSchema{"type": "string"},
+		"ttl":      ref("ttl"),
+		"priority": jsonSchema{"type": "integer", "minimum": 0},
+		"weight":   jsonSchema{"type": "integer", "minimum": 0},
+		"target":   jsonSchema{"type": "string"},
+	}, "type")
+	raw["anyOf"] = []jsonSchema{{"required": []string{"value"}}, {"required": []string{"target"}}}
+
+	extensions := jsonSchema{
+		"type":                 "object",
+		"description":          "Provider-specific options keyed by provider name.",
+		"additionalProperties": jsonSchema{"type": "object"},
+	}
+	if names := providerNames(); len(names) > 0 {
+		extensions["propertyNames"] = jsonSchema{"enum": names}
+	}
+
+	records := jsonSchema{
+		"description": "The records of a domain. A host address or a list of host addresses is shorthand for hosts.",
+		"anyOf": []any{
+			ref("hostAddresses"),
+			object(jsonSchema{
+				"hosts":      ref("hostAddresses"),
+				"cname":      jsonSchema{"type": "string"},
+				"alias":      jsonSchema{"type": "string"},
+				"ns":         delegation,
+				"https":      oneOrMany(serviceBinding),
+				"svcb":       oneOrMany(serviceBinding),
+				"tlsa":       oneOrMany(tlsa),
+				"sshfp":      sshfp,
+				"dkim":       oneOrMany(dkim),
+				"spf":        spf,
+				"dmarc":      dmarc,
+				"mtaSts":     mtaSTS,
+				"tlsRpt":     tlsRPT,
+				"dnssd":      oneOrMany(dnssd),
+				"preset":     oneOrMany(preset),
+				"raw":        oneOrMany(raw),
+				"ptr":        jsonSchema{"type": "boolean"},
+				"extensions": extensions,
+				"ttl":        ref("ttl"),
+			}),
+		},
+	}
+
+	config := object(jsonSchema{
+		"duplicatePolicy":    jsonSchema{"enum": []any{string(ErrorOnDuplicate), string(OverwriteDuplicate)}},
+		"ttl":                ref("ttl"),
+		"allowUnknownFields": jsonSchema{"type": "boolean"},
+	})
+
+	return jsonSchema{
+		"ttl":           ttl,
+		"domains":       oneOrMany(jsonSchema{"type": "string"}),
+		"hostAddress":   hostAddress,
+		"hostAddresses": oneOrMany(ref("hostAddress")),
+		"records":       records,
+		"config":        config,
+	}
+}
+
+// hostAddressPattern returns the regular expression for host address strings.
+func hostAddressPattern() string {
+	flags := make([]string, 0, len(validHostAddressFlags))
+	for flag := range validHostAddressFlags {
+		flags = append(flags, string(flag))
+	}
+	slices.Sort(flags)
+
+	flag := "(" + strings.Join(flags, "|") + ")"
+	return `^(@[^@!\s]+|(` + flag + `(,` + flag + `)*!)?[^@!\s][^!\s]*|` + flag + `(,` + flag + `)*!)$`
+}
+
+func hostAddressFlagSchema() jsonSchema {
+	flags := make([]any, 0, len(validHostAddressFlags))
+	for _, flag := range sortedKeys(validHostAddressFlags) {
+		flags = append(flags, string(flag))
+	}
+	return jsonSchema{"enum": flags}
+}
+
+// providerNames returns the sorted names of the registered providers.
+func providerNames() []any {
+	var names []string
+	for _, p := range ListProviders() {
+		names = append(names, p.Name)
+	}
+	return sortedNames(names)
+}
+
+// presetNames returns the sorted names of the registered presets.
+func presetNames() []any {
+	var names []string
+	for _, p := range ListPresets() {
+		names = append(names, p.Name)
+	}
+	return sortedNames(names)
+}
+
+// sortedNames sorts the names so that the schema does not depend on the order
+// of the registries.
+func sortedNames(names []string) []any {
+	slices.Sort(names)
+	sorted := make([]any, len(names))
+	for i, name := range names {
+		sorted[i] = name
+	}
+	return sorted
+}
+
+func ref(definition string) jsonSchema {
+	return jsonSchema{"$ref": "#/definitions/" + definition}
+}
+
+// oneOrMany returns a schema that accepts either a single item or an array of
+// items, which is how most lists in the profile are parsed.
+func oneOrMany(item jsonSchema) jsonSchema {
+	return jsonSchema{
+		"anyOf": []any{
+			item,
+			jsonSchema{"type": "array", "items": item},
+		},
+	}
+}
+
+func object(properties jsonSchema, required ...string) jsonSchema {
+	s := jsonSchema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func port() jsonSchema {
+	return jsonSchema{"type": "integer", "minimum": 1, "maximum": 65535}
+}
+
+// tlsaField returns the schema of a TLSA field that is either a number within
+// the range of names or one of the names, case-insensitively.
+func tlsaField(names ...string) jsonSchema {
+	return jsonSchema{
+		"anyOf": []any{
+			jsonSchema{"type": "integer", "minimum": 0, "maximum": len(names) - 1},
+			caseInsensitiveEnum(names...),
+		},
+	}
+}
+
+// interpolatable extends the anchored pattern to also match strings containing
+// interpolations, since their values are only validated once the profile is
+// expanded.
+func interpolatable(pattern string) string {
+	return pattern + `|\$\{`
+}
+
+// caseInsensitiveEnum returns the schema of a string that is one of the
+// values, case-insensitively.
+func caseInsensitiveEnum(values ...string) jsonSchema {
+	alternatives := make([]string, len(values))
+	for i, v := range values {
+		var b strings.Builder
+		for _, r := range regexp.QuoteMeta(v) {
+			if lower, upper := strings.ToLower(string(r)), strings.ToUpper(string(r)); lower != upper {
+				b.WriteString("[" + lower + upper + "]")
+			} else {
+				b.WriteRune(r)
+			}
+		}
+		alternatives[i] = b.String()
+	}
+	return jsonSchema{
+		"type":    "string",
+		"pattern": "^(" + strings.Join(alternatives, "|") + ")$",
+	}
+}
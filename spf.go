@@ -0,0 +1,462 @@
+package dnsmill
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// SPFLookupLimit is the maximum number of DNS lookups that an SPF record may
+// cause as defined in RFC 7208, section 4.6.4.
+const SPFLookupLimit = 10
+
+// SPF describes a structured SPF record that is rendered into a TXT record.
+//
+// When the record is converted, the number of DNS lookups it causes is counted
+// by resolving included SPF records. An error is returned if the count
+// exceeds [SPFLookupLimit].
+type SPF struct {
+	// A lists the domains whose A and AAAA records are allowed to send mail.
+	// When parsing, true may be given for the domain of the record itself.
+	A SPFTargets `json:"a,omitempty"`
+	// MX lists the domains whose MX hosts are allowed to send mail.
+	// When parsing, true may be given for the domain of the record itself.
+	MX SPFTargets `json:"mx,omitempty"`
+	// IP4 lists the host addresses whose IPv4 addresses are allowed to send
+	// mail. CIDR ranges such as 192.0.2.0/24 are also accepted.
+	IP4 *HostAddresses `json:"ip4,omitempty"`
+	// IP6 lists the host addresses whose IPv6 addresses are allowed to send
+	// mail. CIDR ranges such as 2001:db8::/32 are also accepted.
+	IP6 *HostAddresses `json:"ip6,omitempty"`
+	// Include lists the domains whose SPF records are included.
+	Include []string `json:"include,omitempty"`
+	// All is the qualifier of the trailing "all" mechanism. If empty, no
+	// "all" mechanism is added.
+	All SPFQualifier `json:"all,omitempty"`
+	// Flatten, if true, replaces the included SPF records with the IP
+	// addresses that they resolve to. This reduces the number of DNS lookups
+	// at the cost of having to run dnsmill again when the included records
+	// change. Only mechanisms that pass are kept.
+	Flatten bool `json:"flatten,omitempty"`
+}
+
+func (s *SPF) UnmarshalJSON(data []byte) error {
+	type alias SPF
+	var spf alias
+	if err := json.Unmarshal(data, &spf); err != nil {
+		return fmt.Errorf("failed to parse SPF: %w", err)
+	}
+
+	*s = SPF(spf)
+	return nil
+}
+
+// Validate validates the SPF record's syntax. It does not count the DNS
+// lookups of the included records, since that requires resolving them. It is
+// called once the profile is expanded, since the domains may be interpolated.
+func (s SPF) Validate() error {
+	for _, domain := range s.Include {
+		if err := validateSPFDomain(domain); err != nil {
+			return fmt.Errorf("invalid SPF include: %w", err)
+		}
+	}
+	for _, domain := range s.A {
+		if domain != "" {
+			if err := validateSPFDomain(domain); err != nil {
+				return fmt.Errorf("invalid SPF a: %w", err)
+			}
+		}
+	}
+	for _, domain := range s.MX {
+		if domain != "" {
+			if err := validateSPFDomain(domain); err != nil {
+				return fmt.Errorf("invalid SPF mx: %w", err)
+			}
+		}
+	}
+	if s.lookups() > SPFLookupLimit {
+		return fmt.Errorf("SPF record has %d DNS lookups, exceeding the limit of %d", s.lookups(), SPFLookupLimit)
+	}
+	return nil
+}
+
+// lookups returns the number of DNS lookups caused by the record's own
+// mechanisms, not counting the lookups of the included records.
+func (s SPF) lookups() int {
+	n := len(s.A) + len(s.MX)
+	if !s.Flatten {
+		n += len(s.Include)
+	}
+	return n
+}
+
+// Convert resolves the host addresses and included records and converts the
+// SPF record into a TXT [libdns.Record] for the given subdomain.
+func (s *SPF) Convert(ctx context.Context, subdomain string, ttl TTL) ([]libdns.Record, error) {
+	value, err := s.TXT(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []libdns.Record{
+		{
+			Type:  "TXT",
+			Name:  subdomain,
+			Value: value,
+			TTL:   ttl.Duration(),
+		},
+	}, nil
+}
+
+// TXT renders the SPF record into its TXT record value, such as
+// `v=spf1 mx ip4:192.0.2.1 include:_spf.example.com -all`.
+func (s SPF) TXT(ctx context.Context) (string, error) {
+	terms := []string{"v=spf1"}
+
+	for _, domain := range s.A {
+		terms = append(terms, joinSPFTerm("a", domain))
+	}
+	for _, domain := range s.MX {
+		terms = append(terms, joinSPFTerm("mx", domain))
+	}
+
+	if s.IP4 != nil {
+		ips, err := resolveSPFAddresses(ctx, *s.IP4, true)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve SPF ip4: %w", err)
+		}
+		for _, ip := range ips {
+			terms = append(terms, "ip4:"+ip)
+		}
+	}
+	if s.IP6 != nil {
+		ips, err := resolveSPFAddresses(ctx, *s.IP6, false)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve SPF ip6: %w", err)
+		}
+		for _, ip := range ips {
+			terms = append(terms, "ip6:"+ip)
+		}
+	}
+
+	lookups := s.lookups()
+
+	for _, domain := range s.Include {
+		if s.Flatten {
+			ips, err := flattenSPF(ctx, domain, nil)
+			if err != nil {
+				return "", fmt.Errorf("failed to flatten SPF include %q: %w", domain, err)
+			}
+			for _, ip := range ips {
+				if strings.Contains(ip, ":") {
+					terms = append(terms, "ip6:"+ip)
+				} else {
+					terms = append(terms, "ip4:"+ip)
+				}
+			}
+			continue
+		}
+
+		n, err := countSPFLookups(ctx, domain, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to count DNS lookups of SPF include %q: %w", domain, err)
+		}
+		lookups += n
+		terms = append(terms, "include:"+domain)
+	}
+
+	if lookups > SPFLookupLimit {
+		return "", fmt.Errorf(
+			"SPF record causes %d DNS lookups, exceeding the limit of %d; consider using flatten",
+			lookups, SPFLookupLimit)
+	}
+
+	if s.All != "" {
+		terms = append(terms, s.All.String()+"all")
+	}
+
+	return strings.Join(terms, " "), nil
+}
+
+// SPFTargets lists the target domains of an SPF mechanism. An empty domain
+// means the domain of the record itself.
+//
+// When parsing, it may either parse true, which is the domain of the record
+// itself, a single domain, or a list of domains.
+type SPFTargets []string
+
+func (t *SPFTargets) UnmarshalJSON(data []byte) error {
+	switch {
+	case bytes.Equal(data, []byte("null")), bytes.Equal(data, []byte("false")):
+		return nil
+	case bytes.Equal(data, []byte("true")):
+		*t = SPFTargets{""}
+		return nil
+	case bytes.HasPrefix(data, []byte{'['}):
+		var domains []string
+		if err := json.Unmarshal(data, &domains); err != nil {
+			return fmt.Errorf("failed to parse SPF targets array: %w", err)
+		}
+		*t = domains
+		return nil
+	default:
+		var domain string
+		if err := json.Unmarshal(data, &domain); err != nil {
+			return fmt.Errorf("failed to parse SPF targets: %w", err)
+		}
+		*t = SPFTargets{domain}
+		return nil
+	}
+}
+
+// SPFQualifier is the qualifier of an SPF mechanism.
+//
+// When parsing, it may either parse the qualifier character or its name,
+// such as "-" or "fail".
+type SPFQualifier string
+
+const (
+	SPFPass     SPFQualifier = "+"
+	SPFFail     SPFQualifier = "-"
+	SPFSoftFail SPFQualifier = "~"
+	SPFNeutral  SPFQualifier = "?"
+)
+
+var spfQualifierNames = map[string]SPFQualifier{
+	"pass":     SPFPass,
+	"fail":     SPFFail,
+	"softfail": SPFSoftFail,
+	"neutral":  SPFNeutral,
+}
+
+func (q *SPFQualifier) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("failed to parse SPFQualifier: %w", err)
+	}
+
+	if named, ok := spfQualifierNames[strings.ToLower(str)]; ok {
+		*q = named
+		return nil
+	}
+
+	switch qualifier := SPFQualifier(str); qualifier {
+	case SPFPass, SPFFail, SPFSoftFail, SPFNeutral:
+		*q = qualifier
+		return nil
+	default:
+		return fmt.Errorf("invalid SPFQualifier %q", str)
+	}
+}
+
+// String returns the qualifier character. The pass qualifier is omitted,
+// since it is the default.
+func (q SPFQualifier) String() string {
+	if q == SPFPass {
+		return ""
+	}
+	return string(q)
+}
+
+func joinSPFTerm(mechanism, domain string) string {
+	if domain == "" {
+		return mechanism
+	}
+	return mechanism + ":" + domain
+}
+
+func validateSPFDomain(domain string) error {
+	if domain == "" {
+		return errors.New("domain must not be empty")
+	}
+	if strings.ContainsAny(domain, " \t\"") {
+		return fmt.Errorf("domain %q must not contain spaces or quotes", domain)
+	}
+	return nil
+}
+
+// resolveSPFAddresses resolves the host addresses into a list of IP addresses
+// or CIDR ranges of the given IP version. CIDR ranges are used verbatim.
+func resolveSPFAddresses(ctx context.Context, hosts HostAddresses, v4 bool) ([]string, error) {
+	var ips []string
+	for _, host := range hosts {
+		if _, ipNet, err := net.ParseCIDR(host.Address); err == nil && len(host.Flags) == 0 {
+			if (ipNet.IP.To4() != nil) != v4 {
+				return nil, fmt.Errorf("CIDR range %q has the wrong IP version", host.Address)
+			}
+			ips = append(ips, ipNet.String())
+			continue
+		}
+
+		addrs, err := host.ResolveIPs(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve address %q: %w", host.String(), err)
+		}
+		for _, addr := range addrs {
+			if (addr.IP.To4() != nil) == v4 {
+				ips = append(ips, addr.IP.String())
+			}
+		}
+	}
+
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses resolved from %d hosts", len(hosts))
+	}
+
+	return ips, nil
+}
+
+// lookupSPF looks up the SPF record of the given domain and returns its
+// terms, excluding the version.
+func lookupSPF(ctx context.Context, domain string) ([]string, error) {
+	txts, err := resolverFrom(ctx).LookupTXT(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var spfs [][]string
+	for _, txt := range txts {
+		terms := strings.Fields(txt)
+		if len(terms) > 0 && strings.EqualFold(terms[0], "v=spf1") {
+			spfs = append(spfs, terms[1:])
+		}
+	}
+
+	switch len(spfs) {
+	case 0:
+		return nil, fmt.Errorf("no SPF record found for %q", domain)
+	case 1:
+		return spfs[0], nil
+	default:
+		return nil, fmt.Errorf("multiple SPF records found for %q", domain)
+	}
+}
+
+// countSPFLookups counts the DNS lookups caused by the mechanisms of the SPF
+// record of the given domain. The lookup of the record itself is not counted,
+// since it is counted by the include or redirect that causes it.
+func countSPFLookups(ctx context.Context, domain string, seen []string) (int, error) {
+	if slices.Contains(seen, domain) {
+		return 0, fmt.Errorf("SPF include loop detected at %q", domain)
+	}
+	seen = append(seen, domain)
+
+	terms, err := lookupSPF(ctx, domain)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, term := range terms {
+		mechanism, target := splitSPFTerm(term)
+		switch mechanism {
+		case "include", "redirect":
+			m, err := countSPFLookups(ctx, target, seen)
+			if err != nil {
+				return 0, err
+			}
+			n += 1 + m
+		case "a", "mx", "ptr", "exists":
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// flattenSPF resolves the SPF record of the given domain into the list of IP
+// addresses and CIDR ranges that pass it.
+func flattenSPF(ctx context.Context, domain string, seen []string) ([]string, error) {
+	if slices.Contains(seen, domain) {
+		return nil, fmt.Errorf("SPF include loop detected at %q", domain)
+	}
+	seen = append(seen, domain)
+
+	terms, err := lookupSPF(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, term := range terms {
+		if strings.Contains(term, "%{") {
+			return nil, fmt.Errorf("cannot flatten SPF term %q with macros", term)
+		}
+
+		if strings.HasPrefix(term, "-") || strings.HasPrefix(term, "~") || strings.HasPrefix(term, "?") {
+			// Only passing mechanisms are kept.
+			continue
+		}
+
+		mechanism, target := splitSPFTerm(term)
+		switch mechanism {
+		case "ip4", "ip6":
+			ips = append(ips, target)
+		case "include", "redirect":
+			included, err := flattenSPF(ctx, target, seen)
+			if err != nil {
+				return nil, err
+			}
+			ips = append(ips, included...)
+		case "a":
+			if strings.Contains(term, "/") {
+				return nil, fmt.Errorf("cannot flatten SPF term %q with a CIDR length", term)
+			}
+			if target == "" {
+				target = domain
+			}
+			addrs, err := resolverFrom(ctx).LookupIPAddr(ctx, target)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve %q: %w", target, err)
+			}
+			for _, addr := range addrs {
+				ips = append(ips, addr.IP.String())
+			}
+		case "mx":
+			if strings.Contains(term, "/") {
+				return nil, fmt.Errorf("cannot flatten SPF term %q with a CIDR length", term)
+			}
+			if target == "" {
+				target = domain
+			}
+			mxs, err := resolverFrom(ctx).LookupMX(ctx, target)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve MX of %q: %w", target, err)
+			}
+			for _, mx := range mxs {
+				addrs, err := resolverFrom(ctx).LookupIPAddr(ctx, mx.Host)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve %q: %w", mx.Host, err)
+				}
+				for _, addr := range addrs {
+					ips = append(ips, addr.IP.String())
+				}
+			}
+		case "all", "exp":
+			// The all mechanism of included records does not apply.
+		default:
+			return nil, fmt.Errorf("cannot flatten SPF term %q", term)
+		}
+	}
+
+	slices.Sort(ips)
+	return slices.Compact(ips), nil
+}
+
+// splitSPFTerm splits an SPF term into its lowercased mechanism or modifier
+// name and its target, stripping the qualifier.
+func splitSPFTerm(term string) (mechanism, target string) {
+	term = strings.TrimLeft(term, "+-~?")
+	if name, value, ok := strings.Cut(term, "="); ok {
+		return strings.ToLower(name), value
+	}
+	name, value, _ := strings.Cut(term, ":")
+	name, _, _ = strings.Cut(name, "/")
+	return strings.ToLower(name), value
+}
@@ -0,0 +1,180 @@
+package dnsmill
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// DefaultSSHHostKeys is the glob pattern of the OpenSSH host public key files
+// that are used by [SSHFP] if no files are given.
+const DefaultSSHHostKeys = "/etc/ssh/ssh_host_*_key.pub"
+
+// SSHFP describes SSHFP records that are generated from OpenSSH public key
+// files. For each key, a SHA-1 and a SHA-256 fingerprint record is created.
+//
+// When parsing, it may either parse:
+//   - true, which uses the default host key files in [DefaultSSHHostKeys].
+//   - A single path or glob pattern to the public key files.
+//   - A list of paths or glob patterns to the public key files.
+//   - The actual [SSHFP] instance itself.
+type SSHFP struct {
+	// Files lists the paths or glob patterns of the OpenSSH public key files.
+	// If empty, [DefaultSSHHostKeys] is used.
+	Files []string `json:"files,omitempty"`
+}
+
+func (s *SSHFP) UnmarshalJSON(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("invalid JSON: empty object")
+	}
+
+	switch data[0] {
+	case 't':
+		var enabled bool
+		if err := json.Unmarshal(data, &enabled); err != nil {
+			return fmt.Errorf("failed to parse SSHFP boolean: %w", err)
+		}
+		*s = SSHFP{}
+
+	case '"':
+		var file string
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("failed to parse SSHFP string: %w", err)
+		}
+		*s = SSHFP{Files: []string{file}}
+
+	case '[':
+		var files []string
+		if err := json.Unmarshal(data, &files); err != nil {
+			return fmt.Errorf("failed to parse SSHFP array: %w", err)
+		}
+		*s = SSHFP{Files: files}
+
+	case '{':
+		type alias SSHFP
+		var sshfp alias
+		if err := json.Unmarshal(data, &sshfp); err != nil {
+			return fmt.Errorf("failed to parse SSHFP: %w", err)
+		}
+		*s = SSHFP(sshfp)
+
+	default:
+		return errors.New("invalid JSON: expected true, string, array, or object")
+	}
+
+	for _, file := range s.Files {
+		if _, err := filepath.Match(file, ""); err != nil {
+			return fmt.Errorf("invalid SSHFP file pattern %q: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+// Convert reads the public key files and converts them into a list of SSHFP
+// [libdns.Record]s for the given subdomain.
+func (s *SSHFP) Convert(subdomain string, ttl TTL) ([]libdns.Record, error) {
+	patterns := s.Files
+	if len(patterns) == 0 {
+		patterns = []string{DefaultSSHHostKeys}
+	}
+
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSHFP file pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no public key files found matching %q", pattern)
+		}
+		files = append(files, matches...)
+	}
+
+	var records []libdns.Record
+	for _, file := range files {
+		algorithm, key, err := readSSHPublicKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key %q: %w", file, err)
+		}
+
+		sha1Sum := sha1.Sum(key)
+		sha256Sum := sha256.Sum256(key)
+
+		records = append(records,
+			libdns.Record{
+				Type:  "SSHFP",
+				Name:  subdomain,
+				Value: fmt.Sprintf("%d 1 %s", algorithm, hex.EncodeToString(sha1Sum[:])),
+				TTL:   ttl.Duration(),
+			},
+			libdns.Record{
+				Type:  "SSHFP",
+				Name:  subdomain,
+				Value: fmt.Sprintf("%d 2 %s", algorithm, hex.EncodeToString(sha256Sum[:])),
+				TTL:   ttl.Duration(),
+			},
+		)
+	}
+
+	return records, nil
+}
+
+// sshfpAlgorithms maps OpenSSH key types to SSHFP algorithm numbers.
+var sshfpAlgorithms = map[string]int{
+	"ssh-rsa":             1,
+	"ssh-dss":             2,
+	"ecdsa-sha2-nistp256": 3,
+	"ecdsa-sha2-nistp384": 3,
+	"ecdsa-sha2-nistp521": 3,
+	"ssh-ed25519":         4,
+	"ssh-ed448":           6,
+}
+
+// readSSHPublicKey reads an OpenSSH public key file in the authorized_keys
+// format and returns its SSHFP algorithm number and wire-format key blob.
+func readSSHPublicKey(path string) (int, []byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	fields := strings.Fields(string(b))
+	if len(fields) < 2 {
+		return 0, nil, errors.New("malformed public key, expected `<type> <base64-key> [comment]`")
+	}
+
+	keyType := fields[0]
+	algorithm, ok := sshfpAlgorithms[keyType]
+	if !ok {
+		return 0, nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return 0, nil, fmt.Errorf("malformed public key: %w", err)
+	}
+
+	// The key blob starts with the key type as a length-prefixed string.
+	if len(key) < 4 {
+		return 0, nil, errors.New("malformed public key: too short")
+	}
+	n := binary.BigEndian.Uint32(key)
+	if uint64(len(key)-4) < uint64(n) || !bytes.Equal(key[4:4+n], []byte(keyType)) {
+		return 0, nil, fmt.Errorf("malformed public key: key blob does not match type %q", keyType)
+	}
+
+	return algorithm, key, nil
+}
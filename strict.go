@@ -0,0 +1,52 @@
+package dnsmill
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unknownFields returns an error for every key within the profile data of
+// the given format that does not match any field, such as a misspelled record
+// type. Unknown keys at the top level of the profile are domains, so they are
+// never reported.
+func unknownFields(format string, data []byte, file string) []error {
+	root, ok := sourceNode(format, data)
+	if !ok {
+		return nil
+	}
+
+	var errs []error
+	var walk func(n profileNode)
+	walk = func(n profileNode) {
+		node := n.node
+		for node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+
+		typ := n.typ
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+
+		if node.Kind == yaml.MappingNode && typ.Kind() == reflect.Struct && typ != profileType {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i]
+				if _, ok := jsonField(typ, key.Value); !ok {
+					errs = append(errs, &ProfileError{
+						Path: joinPath(n.path, key.Value),
+						Pos:  Position{File: file, Line: key.Line, Column: key.Column},
+						Err:  fmt.Errorf("unknown field %q", key.Value),
+					})
+				}
+			}
+		}
+
+		for _, child := range n.children() {
+			walk(child)
+		}
+	}
+	walk(rootProfileNode(root))
+	return errs
+}
@@ -0,0 +1,221 @@
+package dnsmill
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// ServiceBindings represents a list of SVCB or HTTPS records.
+//
+// When parsing, it may either parse a single service binding or a list of
+// service bindings.
+type ServiceBindings []ServiceBinding
+
+func (b *ServiceBindings) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var items []ServiceBinding
+	if bytes.HasPrefix(data, []byte{'['}) {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("failed to parse ServiceBindings array: %w", err)
+		}
+	} else {
+		var item ServiceBinding
+		if err := json.Unmarshal(data, &item); err != nil {
+			return fmt.Errorf("failed to parse ServiceBindings object: %w", err)
+		}
+		items = []ServiceBinding{item}
+	}
+
+	*b = items
+	return nil
+}
+
+// Convert converts the service bindings assigned to the given subdomain into
+// a list of [libdns.Record]s of the given record type, which is either
+// "SVCB" or "HTTPS".
+func (b ServiceBindings) Convert(ctx context.Context, recordType, subdomain string, ttl TTL) ([]libdns.Record, error) {
+	records := make([]libdns.Record, 0, len(b))
+	for _, binding := range b {
+		params, err := binding.SvcParams.Format(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format %s params for target %q: %w", recordType, binding.Target, err)
+		}
+		records = append(records, libdns.Record{
+			Type:     recordType,
+			Name:     subdomain,
+			Value:    params,
+			TTL:      ttl.Duration(),
+			Priority: uint(binding.Priority),
+			Target:   binding.Target,
+		})
+	}
+	return records, nil
+}
+
+// ServiceBinding represents a single SVCB or HTTPS record as described in
+// RFC 9460. The service parameters are embedded into the object.
+type ServiceBinding struct {
+	// Priority is the SvcPriority of the record. A priority of 0 makes the
+	// record an AliasMode record, which must not have any parameters.
+	// It defaults to 1 if not specified.
+	Priority uint16 `json:"priority"`
+	// Target is the TargetName of the record. It defaults to ".", which
+	// means the owner name of the record itself.
+	Target string `json:"target"`
+	// SvcParams are the service parameters of the record.
+	SvcParams
+}
+
+func (b *ServiceBinding) UnmarshalJSON(data []byte) error {
+	type alias ServiceBinding
+	binding := alias{
+		Priority: 1,
+		Target:   ".",
+	}
+	if err := json.Unmarshal(data, &binding); err != nil {
+		return fmt.Errorf("failed to parse ServiceBinding: %w", err)
+	}
+
+	if binding.Target == "" {
+		return errors.New("service binding target must not be empty, use \".\" for the owner name")
+	}
+	if binding.Priority == 0 && !binding.SvcParams.IsZero() {
+		return errors.New("service binding with priority 0 (AliasMode) must not have params")
+	}
+
+	*b = ServiceBinding(binding)
+	return nil
+}
+
+// Validate validates the service parameters of the service binding. It is
+// called once the profile is expanded, since the parameters may be
+// interpolated.
+func (b ServiceBinding) Validate() error {
+	if err := b.SvcParams.Validate(); err != nil {
+		return fmt.Errorf("invalid service binding params: %w", err)
+	}
+	return nil
+}
+
+// SvcParams describes the service parameters of a [ServiceBinding].
+type SvcParams struct {
+	// ALPN lists the ALPN protocol IDs supported by the service, such as
+	// "h2" and "h3".
+	ALPN []string `json:"alpn,omitempty"`
+	// NoDefaultALPN indicates that the default ALPN protocol of the scheme
+	// is not supported. It requires ALPN to be set.
+	NoDefaultALPN bool `json:"no-default-alpn,omitempty"`
+	// Port is the alternative port of the service.
+	Port uint16 `json:"port,omitempty"`
+	// IPv4Hint lists the host addresses whose IPv4 addresses are used as
+	// address hints. Using the same host addresses as the A records keeps the
+	// hints in sync with them.
+	IPv4Hint *HostAddresses `json:"ipv4hint,omitempty"`
+	// IPv6Hint lists the host addresses whose IPv6 addresses are used as
+	// address hints.
+	IPv6Hint *HostAddresses `json:"ipv6hint,omitempty"`
+	// ECH is the base64-encoded ECHConfigList of the service.
+	ECH string `json:"ech,omitempty"`
+}
+
+// IsZero returns true if no service parameters are set.
+func (p SvcParams) IsZero() bool {
+	return len(p.ALPN) == 0 &&
+		!p.NoDefaultALPN &&
+		p.Port == 0 &&
+		p.IPv4Hint == nil &&
+		p.IPv6Hint == nil &&
+		p.ECH == ""
+}
+
+// Validate validates the service parameters.
+func (p SvcParams) Validate() error {
+	for _, id := range p.ALPN {
+		if id == "" || len(id) > 255 {
+			return fmt.Errorf("invalid alpn %q: must be between 1 and 255 bytes", id)
+		}
+		if strings.ContainsAny(id, ",\\\" \t") {
+			return fmt.Errorf("invalid alpn %q: must not contain commas, backslashes, quotes or spaces", id)
+		}
+	}
+	if p.NoDefaultALPN && len(p.ALPN) == 0 {
+		return errors.New("no-default-alpn requires alpn to be set")
+	}
+	if p.ECH != "" {
+		if _, err := base64.StdEncoding.DecodeString(p.ECH); err != nil {
+			return fmt.Errorf("invalid ech: %w", err)
+		}
+	}
+	return nil
+}
+
+// Format resolves the address hints and formats the service parameters in
+// their presentation format, such as `alpn=h2,h3 port=8443`. The parameters
+// are ordered by their key numbers.
+func (p SvcParams) Format(ctx context.Context) (string, error) {
+	var params []string
+
+	if len(p.ALPN) > 0 {
+		params = append(params, "alpn="+strings.Join(p.ALPN, ","))
+	}
+	if p.NoDefaultALPN {
+		params = append(params, "no-default-alpn")
+	}
+	if p.Port != 0 {
+		params = append(params, "port="+strconv.FormatUint(uint64(p.Port), 10))
+	}
+	if p.IPv4Hint != nil {
+		hint, err := resolveAddressHint(ctx, *p.IPv4Hint, true)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve ipv4hint: %w", err)
+		}
+		params = append(params, "ipv4hint="+hint)
+	}
+	if p.ECH != "" {
+		params = append(params, "ech="+p.ECH)
+	}
+	if p.IPv6Hint != nil {
+		hint, err := resolveAddressHint(ctx, *p.IPv6Hint, false)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve ipv6hint: %w", err)
+		}
+		params = append(params, "ipv6hint="+hint)
+	}
+
+	return strings.Join(params, " "), nil
+}
+
+func resolveAddressHint(ctx context.Context, hosts HostAddresses, v4 bool) (string, error) {
+	addrs, err := hosts.ResolveIPs(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var ips []string
+	for _, addr := range addrs {
+		if (addr.IP.To4() != nil) == v4 {
+			ips = append(ips, addr.IP.String())
+		}
+	}
+
+	if len(ips) == 0 {
+		t := "IPv6"
+		if v4 {
+			t = "IPv4"
+		}
+		return "", fmt.Errorf("no %s addresses resolved from %d hosts", t, len(hosts))
+	}
+
+	return strings.Join(ips, ","), nil
+}
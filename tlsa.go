@@ -0,0 +1,285 @@
+package dnsmill
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// TLSARecords represents a list of TLSA records.
+//
+// When parsing, it may either parse a single TLSA record or a list of TLSA
+// records.
+type TLSARecords []TLSA
+
+func (t *TLSARecords) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var items []TLSA
+	if bytes.HasPrefix(data, []byte{'['}) {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("failed to parse TLSARecords array: %w", err)
+		}
+	} else {
+		var item TLSA
+		if err := json.Unmarshal(data, &item); err != nil {
+			return fmt.Errorf("failed to parse TLSARecords object: %w", err)
+		}
+		items = []TLSA{item}
+	}
+
+	*t = items
+	return nil
+}
+
+// Convert converts the TLSA records assigned to the given subdomain into a
+// list of [libdns.Record]s. Certificate files are read at this point, so the
+// records always match the current certificate.
+func (t TLSARecords) Convert(subdomain string, ttl TTL) ([]libdns.Record, error) {
+	records := make([]libdns.Record, 0, len(t))
+	for _, tlsa := range t {
+		data, err := tlsa.AssociationData()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, libdns.Record{
+			Type: "TLSA",
+			Name: subdomain,
+			Value: fmt.Sprintf("%d %d %d %s",
+				tlsa.Usage, tlsa.Selector, tlsa.MatchingType, data),
+			TTL: ttl.Duration(),
+		})
+	}
+	return records, nil
+}
+
+// TLSA represents a single TLSA record as described in RFC 6698. The
+// association data is either given literally or computed from a certificate
+// or public key file.
+//
+// The record must be placed at the name of the service, such as
+// `_25._tcp.mail.example.com`.
+type TLSA struct {
+	// Usage is the certificate usage field. It defaults to DANE-EE (3).
+	Usage TLSAUsage `json:"usage"`
+	// Selector is the selector field. It defaults to SPKI (1).
+	Selector TLSASelector `json:"selector"`
+	// MatchingType is the matching type field. It defaults to SHA2-256 (1).
+	MatchingType TLSAMatchingType `json:"matchingType"`
+	// Data is the hex-encoded certificate association data. It is mutually
+	// exclusive with File.
+	Data string `json:"data,omitempty"`
+	// File is the path to a PEM file containing a certificate or a public
+	// key. Only the first PEM block is used, so for a certificate chain, this
+	// is the end-entity certificate. It is mutually exclusive with Data.
+	File string `json:"file,omitempty"`
+}
+
+func (t *TLSA) UnmarshalJSON(data []byte) error {
+	type alias TLSA
+	tlsa := alias{
+		Usage:        TLSAUsageDANEEE,
+		Selector:     TLSASelectorSPKI,
+		MatchingType: TLSAMatchingTypeSHA256,
+	}
+	if err := json.Unmarshal(data, &tlsa); err != nil {
+		return fmt.Errorf("failed to parse TLSA: %w", err)
+	}
+
+	if (tlsa.Data == "") == (tlsa.File == "") {
+		return errors.New("TLSA must have exactly one of data or file")
+	}
+
+	*t = TLSA(tlsa)
+	return nil
+}
+
+// Validate validates the TLSA record. It does not read the file. It is called
+// once the profile is expanded, since the data may be interpolated.
+func (t TLSA) Validate() error {
+	if (t.Data == "") == (t.File == "") {
+		return errors.New("TLSA must have exactly one of data or file")
+	}
+
+	if t.Data != "" {
+		b, err := hex.DecodeString(t.Data)
+		if err != nil {
+			return fmt.Errorf("invalid TLSA data: %w", err)
+		}
+		if size := t.MatchingType.size(); size != 0 && len(b) != size {
+			return fmt.Errorf(
+				"invalid TLSA data: expected %d bytes for matching type %d, got %d",
+				size, t.MatchingType, len(b))
+		}
+	}
+
+	return nil
+}
+
+// AssociationData returns the hex-encoded certificate association data of the
+// record, reading and hashing the file if needed.
+func (t TLSA) AssociationData() (string, error) {
+	if t.Data != "" {
+		return strings.ToLower(t.Data), nil
+	}
+
+	b, err := os.ReadFile(t.File)
+	if err != nil {
+		return "", fmt.Errorf("failed to read TLSA file: %w", err)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return "", fmt.Errorf("no PEM data found in %q", t.File)
+	}
+
+	var selected []byte
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse certificate in %q: %w", t.File, err)
+		}
+		switch t.Selector {
+		case TLSASelectorCert:
+			selected = cert.Raw
+		case TLSASelectorSPKI:
+			selected = cert.RawSubjectPublicKeyInfo
+		}
+	case "PUBLIC KEY":
+		if t.Selector != TLSASelectorSPKI {
+			return "", fmt.Errorf("%q contains a public key, which requires the SPKI selector", t.File)
+		}
+		if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return "", fmt.Errorf("failed to parse public key in %q: %w", t.File, err)
+		}
+		selected = block.Bytes
+	default:
+		return "", fmt.Errorf("unsupported PEM block %q in %q", block.Type, t.File)
+	}
+
+	switch t.MatchingType {
+	case TLSAMatchingTypeSHA256:
+		sum := sha256.Sum256(selected)
+		selected = sum[:]
+	case TLSAMatchingTypeSHA512:
+		sum := sha512.Sum512(selected)
+		selected = sum[:]
+	}
+
+	return hex.EncodeToString(selected), nil
+}
+
+// TLSAUsage is the certificate usage field of a TLSA record.
+//
+// When parsing, it may either parse the number or its RFC 7218 acronym, such
+// as "DANE-EE".
+type TLSAUsage uint8
+
+const (
+	TLSAUsagePKIXTA TLSAUsage = 0
+	TLSAUsagePKIXEE TLSAUsage = 1
+	TLSAUsageDANETA TLSAUsage = 2
+	TLSAUsageDANEEE TLSAUsage = 3
+)
+
+func (u *TLSAUsage) UnmarshalJSON(data []byte) error {
+	v, err := parseTLSAField(data, "usage", []string{"PKIX-TA", "PKIX-EE", "DANE-TA", "DANE-EE"})
+	if err != nil {
+		return err
+	}
+	*u = TLSAUsage(v)
+	return nil
+}
+
+// TLSASelector is the selector field of a TLSA record.
+//
+// When parsing, it may either parse the number or its RFC 7218 acronym, such
+// as "SPKI".
+type TLSASelector uint8
+
+const (
+	TLSASelectorCert TLSASelector = 0
+	TLSASelectorSPKI TLSASelector = 1
+)
+
+func (s *TLSASelector) UnmarshalJSON(data []byte) error {
+	v, err := parseTLSAField(data, "selector", []string{"Cert", "SPKI"})
+	if err != nil {
+		return err
+	}
+	*s = TLSASelector(v)
+	return nil
+}
+
+// TLSAMatchingType is the matching type field of a TLSA record.
+//
+// When parsing, it may either parse the number or its RFC 7218 acronym, such
+// as "SHA2-256".
+type TLSAMatchingType uint8
+
+const (
+	TLSAMatchingTypeFull   TLSAMatchingType = 0
+	TLSAMatchingTypeSHA256 TLSAMatchingType = 1
+	TLSAMatchingTypeSHA512 TLSAMatchingType = 2
+)
+
+func (m *TLSAMatchingType) UnmarshalJSON(data []byte) error {
+	v, err := parseTLSAField(data, "matching type", []string{"Full", "SHA2-256", "SHA2-512"})
+	if err != nil {
+		return err
+	}
+	*m = TLSAMatchingType(v)
+	return nil
+}
+
+// size returns the size of the association data in bytes, or 0 if it is not
+// fixed.
+func (m TLSAMatchingType) size() int {
+	switch m {
+	case TLSAMatchingTypeSHA256:
+		return sha256.Size
+	case TLSAMatchingTypeSHA512:
+		return sha512.Size
+	default:
+		return 0
+	}
+}
+
+// parseTLSAField parses a TLSA field as either a number within the range of
+// names or one of the names, case-insensitively.
+func parseTLSAField(data []byte, field string, names []string) (uint8, error) {
+	var str string
+	if bytes.HasPrefix(data, []byte{'"'}) {
+		if err := json.Unmarshal(data, &str); err != nil {
+			return 0, fmt.Errorf("failed to parse TLSA %s: %w", field, err)
+		}
+		for i, name := range names {
+			if strings.EqualFold(str, name) {
+				return uint8(i), nil
+			}
+		}
+	} else {
+		str = string(data)
+	}
+
+	v, err := strconv.ParseUint(str, 10, 8)
+	if err != nil || int(v) >= len(names) {
+		return 0, fmt.Errorf("invalid TLSA %s %q, expected 0-%d or one of %q",
+			field, str, len(names)-1, names)
+	}
+	return uint8(v), nil
+}
@@ -0,0 +1,112 @@
+package dnsmill
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TTL is the time-to-live of a DNS record. A zero TTL means that the TTL is
+// not set and should be inherited from the zone or profile, falling back to the
+// default TTL of the DNS provider.
+//
+// When parsing, it may either parse a number of seconds or a Go duration
+// string, such as "60s" or "1h30m". TTLs must be a whole number of seconds.
+type TTL time.Duration
+
+// ParseTTL parses a TTL string. The string may either be a number of seconds
+// or a Go duration string.
+func ParseTTL(str string) (TTL, error) {
+	var d time.Duration
+
+	if secs, err := strconv.ParseInt(str, 10, 64); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else {
+		d, err = time.ParseDuration(str)
+		if err != nil {
+			return 0, fmt.Errorf("invalid TTL %q: %w", str, err)
+		}
+	}
+
+	ttl := TTL(d)
+	if err := ttl.Validate(); err != nil {
+		return 0, err
+	}
+
+	return ttl, nil
+}
+
+func (t *TTL) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if bytes.HasPrefix(data, []byte{'"'}) {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return fmt.Errorf("failed to parse TTL string: %w", err)
+		}
+
+		ttl, err := ParseTTL(str)
+		if err != nil {
+			return err
+		}
+
+		*t = ttl
+		return nil
+	}
+
+	var secs int64
+	if err := json.Unmarshal(data, &secs); err != nil {
+		return fmt.Errorf("failed to parse TTL seconds: %w", err)
+	}
+
+	ttl := TTL(time.Duration(secs) * time.Second)
+	if err := ttl.Validate(); err != nil {
+		return err
+	}
+
+	*t = ttl
+	return nil
+}
+
+// Validate validates the TTL.
+func (t TTL) Validate() error {
+	d := time.Duration(t)
+	if d < 0 {
+		return fmt.Errorf("invalid TTL %s: must not be negative", d)
+	}
+	if d%time.Second != 0 {
+		return fmt.Errorf("invalid TTL %s: must be a whole number of seconds", d)
+	}
+	return nil
+}
+
+// MarshalJSON marshals the TTL as a Go duration string without zero units,
+// such as "1h" or "1h30m".
+func (t TTL) MarshalJSON() ([]byte, error) {
+	str := t.Duration().String()
+	if strings.HasSuffix(str, "m0s") {
+		str = strings.TrimSuffix(str, "0s")
+	}
+	if strings.HasSuffix(str, "h0m") {
+		str = strings.TrimSuffix(str, "0m")
+	}
+	return json.Marshal(str)
+}
+
+// Duration returns the TTL as a [time.Duration].
+func (t TTL) Duration() time.Duration {
+	return time.Duration(t)
+}
+
+// Or returns t if it is set, otherwise it returns other.
+func (t TTL) Or(other TTL) TTL {
+	if t != 0 {
+		return t
+	}
+	return other
+}
@@ -0,0 +1,212 @@
+package dnsmill
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// staticRecord is a record that is known from the profile alone, without any
+// network or file access.
+type staticRecord struct {
+	// Source is the domain whose records declare the record.
+	Source Domain
+	// Zone is the zone that the record is created in.
+	Zone Domain
+	// Name is the fully-qualified name of the record.
+	Name Domain
+	// Type is the type of the record.
+	Type string
+	// Value is the value of the record. It is empty if the value is only
+	// known once the profile is applied, such as for resolved hosts.
+	Value string
+}
+
+func (r staticRecord) String() string {
+	if r.Value == "" {
+		return fmt.Sprintf("%s record at %q declared by %q", r.Type, r.Name, r.Source)
+	}
+	return fmt.Sprintf("%s record %q at %q declared by %q", r.Type, r.Value, r.Name, r.Source)
+}
+
+// staticRecords returns the records of the given domain that are known without
+// any network or file access.
+func (r *Records) staticRecords(domain Domain) []libdns.Record {
+	var records []libdns.Record
+	add := func(name, recordType, value string) {
+		records = append(records, libdns.Record{Type: recordType, Name: name, Value: value})
+	}
+
+	// Converting with the domain as the subdomain yields fully-qualified
+	// names.
+	name := string(domain)
+
+	if r.Hosts != nil {
+		for _, addr := range *r.Hosts {
+			ip := net.ParseIP(addr.Address)
+			switch {
+			case ip != nil && len(addr.Flags) == 0 && ip.To4() != nil:
+				add(name, "A", ip.String())
+			case ip != nil && len(addr.Flags) == 0:
+				add(name, "AAAA", ip.String())
+			default:
+				add(name, "A", "")
+			}
+		}
+	}
+	if r.CNAME != nil {
+		add(name, "CNAME", *r.CNAME)
+	}
+	if r.Alias != nil {
+		add(name, "A", "")
+	}
+	if r.NS != nil {
+		records = append(records, r.NS.Convert(name, 0)...)
+	}
+	if r.HTTPS != nil {
+		add(name, "HTTPS", "")
+	}
+	if r.SVCB != nil {
+		add(name, "SVCB", "")
+	}
+	if r.TLSA != nil {
+		add(name, "TLSA", "")
+	}
+	if r.SSHFP != nil {
+		add(name, "SSHFP", "")
+	}
+	for _, key := range r.DKIM {
+		add(prefixSubdomain(key.Selector+"._domainkey", name), "TXT", "")
+	}
+	if r.SPF != nil {
+		add(name, "TXT", "")
+	}
+	if r.DMARC != nil {
+		records = append(records, r.DMARC.Convert(name, 0)...)
+	}
+	if r.MTASTS != nil {
+		add(prefixSubdomain("_mta-sts", name), "TXT", "")
+	}
+	if r.TLSRPT != nil {
+		records = append(records, r.TLSRPT.Convert(name, 0)...)
+	}
+	if r.DNSSD != nil {
+		records = append(records, r.DNSSD.Convert(domain, name, 0)...)
+	}
+	if r.Preset != nil {
+		// Invalid presets are reported by PresetConfigs.Validate.
+		if recs, err := r.Preset.Convert(domain, name, 0); err == nil {
+			records = append(records, recs...)
+		}
+	}
+	if r.Raw != nil {
+		records = append(records, r.Raw.Convert(name, 0)...)
+	}
+
+	return records
+}
+
+// validateRecords statically validates the records of the profile after they
+// are mapped to their zones. It returns every problem that is found rather
+// than only the first one.
+func validateRecords(p *Profile, rootDomains []mappedRootDomain) []error {
+	var errs []error
+
+	byName := make(map[Domain][]staticRecord)
+	var names []Domain
+
+	for _, root := range rootDomains {
+		for _, domain := range root.Subdomains.sortedDomains() {
+			records := root.Subdomains[domain]
+			for _, rec := range records.staticRecords(domain) {
+				r := staticRecord{
+					Source: domain,
+					Zone:   root.RootDomain,
+					Name:   Domain(rec.Name),
+					Type:   rec.Type,
+					Value:  rec.Value,
+				}
+
+				if zone := mostSpecificZone(rootDomains, r.Name); zone != r.Zone && !isDelegationOf(r, zone) {
+					errs = append(errs, errorAt(recordsPath(r.Source), fmt.Errorf(
+						"%s is within zone %q but would be created in zone %q",
+						r, zone, r.Zone)))
+				}
+
+				if _, ok := byName[r.Name]; !ok {
+					names = append(names, r.Name)
+				}
+				byName[r.Name] = append(byName[r.Name], r)
+			}
+		}
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		records := byName[name]
+
+		for i, r := range records {
+			if r.Type != "CNAME" {
+				continue
+			}
+			if r.Name == r.Zone {
+				errs = append(errs, errorAt(recordsPath(r.Source)+".cname", fmt.Errorf(
+					"%s is at the apex of zone %q, use alias instead",
+					r, r.Zone)))
+			}
+			for j, other := range records {
+				if i != j && (other.Type != "CNAME" || j > i) {
+					errs = append(errs, errorAt(recordsPath(r.Source)+".cname", fmt.Errorf(
+						"%s conflicts with %s, a CNAME cannot coexist with other records",
+						r, other)))
+				}
+			}
+
+			// Only targets declared without any records are known to be
+			// dangling without looking them up.
+			target := Domain(strings.TrimSuffix(r.Value, "."))
+			if _, declared := p.Records[target]; declared && len(byName[target]) == 0 {
+				errs = append(errs, errorAt(recordsPath(r.Source)+".cname", fmt.Errorf(
+					"%s points to %q, which is declared without any records",
+					r, target)))
+			}
+		}
+
+		for i, r := range records {
+			if r.Value == "" {
+				continue
+			}
+			if j := slices.IndexFunc(records[:i], func(other staticRecord) bool {
+				return other.Type == r.Type && other.Value == r.Value
+			}); j != -1 {
+				errs = append(errs, errorAt(recordsPath(r.Source), fmt.Errorf(
+					"duplicate %s record %q at %q declared by %q and %q",
+					r.Type, r.Value, r.Name, records[j].Source, r.Source)))
+			}
+		}
+	}
+
+	return errs
+}
+
+// mostSpecificZone returns the most specific zone that contains the given
+// domain, or an empty string if none does.
+func mostSpecificZone(rootDomains []mappedRootDomain, domain Domain) Domain {
+	var zone Domain
+	for _, root := range rootDomains {
+		if _, ok := domain.SubdomainOf(root.RootDomain); ok && len(root.RootDomain) > len(zone) {
+			zone = root.RootDomain
+		}
+	}
+	return zone
+}
+
+// isDelegationOf returns true if the record is an NS record delegating the
+// given zone, which belongs to the parent zone.
+func isDelegationOf(r staticRecord, zone Domain) bool {
+	return r.Type == "NS" && r.Name == zone
+}
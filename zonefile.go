@@ -0,0 +1,655 @@
+package dnsmill
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// Zone is a DNS zone as it appears in an RFC 1035 master file, also known as
+// a BIND zone file.
+type Zone struct {
+	// Origin is the domain of the zone.
+	Origin Domain
+	// TTL is the default TTL of the records, which is written as the $TTL
+	// directive. It is zero if the zone has no default TTL.
+	TTL TTL
+	// Records are the records of the zone. Their names are relative to the
+	// origin, with "@" being the origin itself. Record values are kept in the
+	// same form as [Records.Convert] produces them, so MX, SRV, HTTPS and
+	// SVCB records have their priority, weight and target in the fields of
+	// [libdns.Record] rather than in the value.
+	Records []libdns.Record
+}
+
+// ParseZone parses a zone from the master file data. The origin is used for
+// relative names until the first $ORIGIN directive, and it may be empty if
+// the file has one. The origin of the zone is the first origin that is known,
+// and every record must be within it.
+//
+// Only the IN class is supported. $INCLUDE and $GENERATE directives are not
+// supported.
+func ParseZone(r io.Reader, origin Domain) (*Zone, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := lexZone(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	p := zoneParser{
+		zone:   &Zone{Origin: origin},
+		origin: origin,
+	}
+	for _, entry := range entries {
+		if err := p.parse(entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", entry.line, err)
+		}
+	}
+
+	if p.zone.Origin == "" {
+		return nil, errors.New("zone has no origin")
+	}
+	return p.zone, nil
+}
+
+// zoneToken is a token within a master file.
+type zoneToken struct {
+	text   string
+	quoted bool
+}
+
+// zoneEntry is a directive or record within a master file, which may span
+// multiple lines using parentheses.
+type zoneEntry struct {
+	line int
+	// indented is true if the entry starts with whitespace, in which case a
+	// record has the owner of the previous record.
+	indented bool
+	tokens   []zoneToken
+}
+
+// lexZone splits the master file data into entries of tokens, dropping
+// comments. Escapes are decoded within quoted strings and kept as they are
+// otherwise.
+func lexZone(data string) ([]zoneEntry, error) {
+	var (
+		entries []zoneEntry
+		entry   zoneEntry
+		token   strings.Builder
+		inToken bool
+		quoted  bool
+		inQuote bool
+		depth   int
+	)
+
+	line := 1
+	startOfLine := true
+
+	flushToken := func() {
+		if inToken {
+			entry.tokens = append(entry.tokens, zoneToken{text: token.String(), quoted: quoted})
+		}
+		token.Reset()
+		inToken = false
+		quoted = false
+	}
+	flushEntry := func() {
+		flushToken()
+		if len(entry.tokens) > 0 {
+			entries = append(entries, entry)
+		}
+		entry = zoneEntry{}
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if startOfLine {
+			entry.line = line
+			entry.indented = c == ' ' || c == '\t'
+			startOfLine = false
+		}
+
+		if inQuote {
+			switch c {
+			case '"':
+				inQuote = false
+				flushToken()
+			case '\\':
+				if i+1 >= len(data) {
+					return nil, fmt.Errorf("line %d: unterminated escape", line)
+				}
+				if i+3 < len(data) && isDigits(data[i+1:i+4]) {
+					n, _ := strconv.Atoi(data[i+1 : i+4])
+					if n > 255 {
+						return nil, fmt.Errorf("line %d: invalid escape \\%s", line, data[i+1:i+4])
+					}
+					token.WriteByte(byte(n))
+					i += 3
+				} else {
+					token.WriteByte(data[i+1])
+					i++
+				}
+			case '\n':
+				return nil, fmt.Errorf("line %d: unterminated quoted string", line)
+			default:
+				token.WriteByte(c)
+			}
+			continue
+		}
+
+		switch c {
+		case ';':
+			for i+1 < len(data) && data[i+1] != '\n' {
+				i++
+			}
+		case '"':
+			if inToken {
+				// A quoted string within a token, such as the values of
+				// SVCB parameters, is kept as it is.
+				j := i + 1
+				for j < len(data) && data[j] != '"' && data[j] != '\n' {
+					if data[j] == '\\' {
+						j++
+					}
+					j++
+				}
+				if j >= len(data) || data[j] != '"' {
+					return nil, fmt.Errorf("line %d: unterminated quoted string", line)
+				}
+				token.WriteString(data[i : j+1])
+				i = j
+				continue
+			}
+			inToken = true
+			quoted = true
+			inQuote = true
+		case '(':
+			flushToken()
+			depth++
+		case ')':
+			flushToken()
+			if depth == 0 {
+				return nil, fmt.Errorf("line %d: unbalanced parenthesis", line)
+			}
+			depth--
+		case ' ', '\t', '\r':
+			flushToken()
+		case '\n':
+			if depth == 0 {
+				flushEntry()
+				startOfLine = true
+			} else {
+				flushToken()
+			}
+			line++
+		case '\\':
+			inToken = true
+			token.WriteByte(c)
+			if i+1 < len(data) {
+				token.WriteByte(data[i+1])
+				i++
+			}
+		default:
+			inToken = true
+			token.WriteByte(c)
+		}
+	}
+
+	if inQuote {
+		return nil, fmt.Errorf("line %d: unterminated quoted string", line)
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("line %d: unclosed parenthesis", line)
+	}
+	flushEntry()
+
+	return entries, nil
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+type zoneParser struct {
+	zone *Zone
+	// origin is the current origin, which is changed by $ORIGIN.
+	origin Domain
+	// defaultTTL is the TTL set by $TTL.
+	defaultTTL time.Duration
+	// lastTTL is the TTL of the previous record, which is used if there is
+	// no $TTL.
+	lastTTL time.Duration
+	// lastOwner is the owner of the previous record.
+	lastOwner string
+}
+
+func (p *zoneParser) parse(entry zoneEntry) error {
+	tokens := entry.tokens
+
+	if !entry.indented && strings.HasPrefix(tokens[0].text, "$") {
+		directive := strings.ToUpper(tokens[0].text)
+		switch directive {
+		case "$ORIGIN":
+			if len(tokens) != 2 {
+				return errors.New("$ORIGIN must have exactly one domain name")
+			}
+			origin, err := p.absoluteName(tokens[1].text)
+			if err != nil {
+				return err
+			}
+			p.origin = Domain(strings.TrimSuffix(origin, "."))
+			if p.zone.Origin == "" {
+				p.zone.Origin = p.origin
+			}
+		case "$TTL":
+			if len(tokens) != 2 {
+				return errors.New("$TTL must have exactly one TTL")
+			}
+			ttl, ok := parseZoneTTL(tokens[1].text)
+			if !ok {
+				return fmt.Errorf("invalid $TTL %q", tokens[1].text)
+			}
+			p.defaultTTL = ttl
+			if p.zone.TTL == 0 {
+				p.zone.TTL = TTL(ttl)
+			}
+		default:
+			return fmt.Errorf("unsupported directive %s", directive)
+		}
+		return nil
+	}
+
+	owner := p.lastOwner
+	if !entry.indented {
+		name, err := p.absoluteName(tokens[0].text)
+		if err != nil {
+			return err
+		}
+		owner = name
+		tokens = tokens[1:]
+	}
+	if owner == "" {
+		return errors.New("record has no owner name")
+	}
+	p.lastOwner = owner
+
+	if p.zone.Origin == "" {
+		if p.origin == "" {
+			return errors.New("record before $ORIGIN without an origin")
+		}
+		p.zone.Origin = p.origin
+	}
+	name, err := p.relativeName(owner)
+	if err != nil {
+		return err
+	}
+
+	// The TTL and class are both optional and may be given in either order.
+	var ttl time.Duration
+	var hasTTL bool
+	for len(tokens) > 0 {
+		if isZoneClass(tokens[0].text) {
+			if !strings.EqualFold(tokens[0].text, "IN") {
+				return fmt.Errorf("unsupported class %s", strings.ToUpper(tokens[0].text))
+			}
+		} else if t, ok := parseZoneTTL(tokens[0].text); ok && !hasTTL {
+			ttl, hasTTL = t, true
+		} else {
+			break
+		}
+		tokens = tokens[1:]
+	}
+
+	switch {
+	case hasTTL:
+		p.lastTTL = ttl
+	case p.defaultTTL != 0:
+		ttl = p.defaultTTL
+	default:
+		ttl = p.lastTTL
+	}
+
+	if len(tokens) == 0 {
+		return errors.New("record has no type")
+	}
+	recordType := strings.ToUpper(tokens[0].text)
+	if !rrTypeRegex.MatchString(recordType) {
+		return fmt.Errorf("invalid record type %q", tokens[0].text)
+	}
+	if len(tokens) == 1 {
+		return fmt.Errorf("%s record has no data", recordType)
+	}
+
+	record, err := p.parseData(recordType, tokens[1:])
+	if err != nil {
+		return fmt.Errorf("invalid %s record: %w", recordType, err)
+	}
+	record.Type = recordType
+	record.Name = name
+	record.TTL = ttl
+
+	p.zone.Records = append(p.zone.Records, record)
+	return nil
+}
+
+// parseData parses the data of a record of the given type.
+func (p *zoneParser) parseData(recordType string, data []zoneToken) (libdns.Record, error) {
+	var record libdns.Record
+
+	wantTokens := func(n int) error {
+		if len(data) != n {
+			return fmt.Errorf("expected %d fields, got %d", n, len(data))
+		}
+		return nil
+	}
+
+	switch recordType {
+	case "CNAME", "DNAME", "NS", "PTR":
+		if err := wantTokens(1); err != nil {
+			return record, err
+		}
+		name, err := p.absoluteName(data[0].text)
+		if err != nil {
+			return record, err
+		}
+		record.Value = name
+
+	case "MX":
+		if err := wantTokens(2); err != nil {
+			return record, err
+		}
+		priority, err := strconv.ParseUint(data[0].text, 10, 16)
+		if err != nil {
+			return record, fmt.Errorf("invalid preference %q", data[0].text)
+		}
+		name, err := p.absoluteName(data[1].text)
+		if err != nil {
+			return record, err
+		}
+		record.Priority = uint(priority)
+		record.Value = name
+
+	case "SRV":
+		if err := wantTokens(4); err != nil {
+			return record, err
+		}
+		var fields [3]uint64
+		for i := range fields {
+			n, err := strconv.ParseUint(data[i].text, 10, 16)
+			if err != nil {
+				return record, fmt.Errorf("invalid number %q", data[i].text)
+			}
+			fields[i] = n
+		}
+		target, err := p.absoluteName(data[3].text)
+		if err != nil {
+			return record, err
+		}
+		record.Priority = uint(fields[0])
+		record.Weight = uint(fields[1])
+		record.Value = fmt.Sprintf("%d %s", fields[2], target)
+
+	case "HTTPS", "SVCB":
+		if len(data) < 2 {
+			return record, fmt.Errorf("expected at least 2 fields, got %d", len(data))
+		}
+		priority, err := strconv.ParseUint(data[0].text, 10, 16)
+		if err != nil {
+			return record, fmt.Errorf("invalid priority %q", data[0].text)
+		}
+		target := data[1].text
+		if target != "." {
+			if target, err = p.absoluteName(target); err != nil {
+				return record, err
+			}
+		}
+		record.Priority = uint(priority)
+		record.Target = target
+		record.Value = joinZoneTokens(data[2:])
+
+	case "TXT", "SPF":
+		// The character strings of a record are concatenated, since the
+		// 255-byte limit is an artifact of the wire format.
+		var value strings.Builder
+		for _, token := range data {
+			value.WriteString(token.text)
+		}
+		record.Value = value.String()
+
+	default:
+		record.Value = joinZoneTokens(data)
+	}
+
+	return record, nil
+}
+
+// absoluteName returns the name as a fully qualified domain name with a
+// trailing dot, resolving relative names against the current origin.
+func (p *zoneParser) absoluteName(name string) (string, error) {
+	switch {
+	case strings.HasSuffix(name, "."):
+		return name, nil
+	case p.origin == "":
+		return "", fmt.Errorf("relative name %q without an origin", name)
+	case name == "@":
+		return string(p.origin) + ".", nil
+	default:
+		return name + "." + string(p.origin) + ".", nil
+	}
+}
+
+// relativeName returns the fully qualified name relative to the origin of
+// the zone.
+func (p *zoneParser) relativeName(fqdn string) (string, error) {
+	domain := Domain(strings.TrimSuffix(fqdn, "."))
+	subdomain, ok := domain.SubdomainOf(p.zone.Origin)
+	if !ok {
+		return "", fmt.Errorf("%q is outside of zone %q", domain, p.zone.Origin)
+	}
+	if subdomain == "" {
+		return "@", nil
+	}
+	return subdomain, nil
+}
+
+func isZoneClass(s string) bool {
+	switch strings.ToUpper(s) {
+	case "IN", "CH", "CS", "HS":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseZoneTTL parses a TTL in seconds or in the BIND format, such as "1h30m".
+func parseZoneTTL(s string) (time.Duration, bool) {
+	if isDigits(s) {
+		n, err := strconv.ParseUint(s, 10, 32)
+		return time.Duration(n) * time.Second, err == nil
+	}
+
+	var ttl time.Duration
+	for s != "" {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return 0, false
+		}
+		n, err := strconv.ParseUint(s[:i], 10, 32)
+		if err != nil {
+			return 0, false
+		}
+		var unit time.Duration
+		switch s[i] {
+		case 's', 'S':
+			unit = time.Second
+		case 'm', 'M':
+			unit = time.Minute
+		case 'h', 'H':
+			unit = time.Hour
+		case 'd', 'D':
+			unit = 24 * time.Hour
+		case 'w', 'W':
+			unit = 7 * 24 * time.Hour
+		default:
+			return 0, false
+		}
+		ttl += time.Duration(n) * unit
+		s = s[i+1:]
+	}
+	return ttl, true
+}
+
+// joinZoneTokens joins the tokens back into master file text, quoting the
+// tokens that were quoted.
+func joinZoneTokens(tokens []zoneToken) string {
+	texts := make([]string, len(tokens))
+	for i, token := range tokens {
+		texts[i] = token.text
+		if token.quoted {
+			texts[i] = quoteZoneString(token.text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// quoteZoneString quotes the string as a master file character string.
+func quoteZoneString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// isQuotedZoneStrings returns true if the value is a sequence of quoted
+// character strings in presentation format.
+func isQuotedZoneStrings(value string) bool {
+	if !strings.HasPrefix(value, `"`) {
+		return false
+	}
+	entries, err := lexZone(value)
+	if err != nil || len(entries) != 1 {
+		return false
+	}
+	for _, token := range entries[0].tokens {
+		if !token.quoted || len(token.text) > maxZoneStringLength {
+			return false
+		}
+	}
+	return true
+}
+
+// maxZoneStringLength is the maximum length of a character string.
+const maxZoneStringLength = 255
+
+// WriteTo writes the zone as a master file. The file starts with the $ORIGIN
+// and $TTL directives, and the records are sorted by name and type, with
+// their names relative to the origin. Records only have their TTL written if
+// it differs from the zone's TTL.
+func (z *Zone) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "$ORIGIN %s.\n", z.Origin)
+	if z.TTL != 0 {
+		fmt.Fprintf(&buf, "$TTL %d\n", int64(z.TTL.Duration()/time.Second))
+	}
+
+	records := slices.Clone(z.Records)
+	slices.SortStableFunc(records, func(a, b libdns.Record) int {
+		return cmp.Or(
+			compareZoneNames(a.Name, b.Name),
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.Priority, b.Priority),
+			cmp.Compare(a.Value, b.Value),
+		)
+	})
+
+	tw := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
+	for _, record := range records {
+		name := record.Name
+		if name == "" {
+			name = "@"
+		}
+		var ttl string
+		if TTL(record.TTL) != z.TTL {
+			ttl = strconv.FormatInt(int64(record.TTL/time.Second), 10)
+		}
+		fmt.Fprintf(tw, "%s\t%s\tIN\t%s\t%s\n", name, ttl, record.Type, formatZoneData(record))
+	}
+	if err := tw.Flush(); err != nil {
+		return 0, err
+	}
+
+	return buf.WriteTo(w)
+}
+
+// compareZoneNames compares the relative names of records, sorting the
+// origin first.
+func compareZoneNames(a, b string) int {
+	isOrigin := func(name string) bool { return name == "" || name == "@" }
+	switch {
+	case isOrigin(a) && isOrigin(b):
+		return 0
+	case isOrigin(a):
+		return -1
+	case isOrigin(b):
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// formatZoneData formats the data of the record as it appears in a master
+// file.
+func formatZoneData(record libdns.Record) string {
+	switch record.Type {
+	case "MX":
+		return fmt.Sprintf("%d %s", record.Priority, record.Value)
+	case "SRV":
+		return fmt.Sprintf("%d %d %s", record.Priority, record.Weight, record.Value)
+	case "HTTPS", "SVCB":
+		target := cmp.Or(record.Target, ".")
+		return strings.TrimSpace(fmt.Sprintf("%d %s %s", record.Priority, target, record.Value))
+	case "TXT", "SPF":
+		value := record.Value
+		if isQuotedZoneStrings(value) {
+			// The value is already split into character strings, such as
+			// the key/value pairs of DNS-SD services.
+			return value
+		}
+		var strs []string
+		for len(value) > maxZoneStringLength {
+			strs = append(strs, quoteZoneString(value[:maxZoneStringLength]))
+			value = value[maxZoneStringLength:]
+		}
+		strs = append(strs, quoteZoneString(value))
+		return strings.Join(strs, " ")
+	default:
+		return record.Value
+	}
+}